@@ -10,6 +10,7 @@ type (
 	Read struct {
 		ID      string
 		Preload map[string]string
+		Expand  []*Expand
 	}
 
 	Update struct {
@@ -29,6 +30,8 @@ type (
 		Where   map[string]string
 		Sort    map[string]string
 		Preload map[string]string
+		Expand  []*Expand
+		Query   string // full text search, ordered by rank unless sorted
 		Hooks   []model.Hook
 	}
 
@@ -38,6 +41,43 @@ type (
 		Preload map[string]string
 		Hooks   []model.Hook
 	}
+
+	// Aggregate groups the rows matching where by the GroupBy columns, and aggregates the rest by function.
+	// Each row of the result has the group columns and the aggregates, named count, sum_<column>, avg_<column> and so on.
+	Aggregate struct {
+		Where   map[string]string
+		GroupBy []string // columns
+		Count   bool
+		Sum     []string // columns
+		Avg     []string // columns
+		Min     []string // columns
+		Max     []string // columns
+		Hooks   []model.Hook
+	}
+
+	// Facets counts the distinct values of each field (column) of the rows matching where and the full text search,
+	// the Take most common values of each field
+	Facets struct {
+		Fields []string // columns
+		Where  map[string]string
+		Query  string
+		Take   int // 0 means all
+		Hooks  []model.Hook
+	}
+
+	// Facet is a distinct value and how many rows have it
+	Facet struct {
+		Value any   `json:"value"`
+		Count int64 `json:"count"`
+	}
+
+	// Expand preloads a relation by path, ie Pets.Toys, with optional conditions on the related rows
+	Expand struct {
+		Relation string            // field (or json) names, dot separated
+		Where    map[string]string // equality filters by column
+		Sort     map[string]string // column, asc or desc
+		Take     int               // 0 means all, limits all preloaded rows of the relation
+	}
 )
 
 func NewCreate(it any) *Create {
@@ -63,3 +103,11 @@ func NewSearch(skip int, take int, where map[string]string, sort map[string]stri
 func NewPatch(id string, data map[string]any, preload map[string]string, hooks []model.Hook) *Patch {
 	return &Patch{ID: id, Data: data, Preload: preload, Hooks: hooks}
 }
+
+func NewAggregate(where map[string]string, groupBy []string, hooks []model.Hook) *Aggregate {
+	return &Aggregate{Where: where, GroupBy: groupBy, Hooks: hooks}
+}
+
+func NewFacets(fields []string, where map[string]string, take int, hooks []model.Hook) *Facets {
+	return &Facets{Fields: fields, Where: where, Take: take, Hooks: hooks}
+}
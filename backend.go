@@ -0,0 +1,229 @@
+package quickapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strings"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/client"
+	"github.com/Meduzz/quickapi/http"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/storage"
+	"gorm.io/gorm"
+)
+
+type (
+	// backend is where the data commands read and write, a server or a db
+	backend interface {
+		Specs(ctx context.Context) ([]*http.Spec, error)
+		Read(ctx context.Context, name string, req *api.Read) (any, error)
+		Search(ctx context.Context, name string, req *api.Search, scopes client.Scopes) (any, error)
+		Create(ctx context.Context, name string, data map[string]any) (any, error)
+		Patch(ctx context.Context, name string, req *api.Patch, scopes client.Scopes) (any, error)
+		Delete(ctx context.Context, name string, id string, scopes client.Scopes) error
+	}
+
+	// remoteBackend talks to a running server, entities are found with /_discover
+	remoteBackend struct {
+		server string
+	}
+
+	// dbBackend works directly with the db, without lifecycle hooks or events
+	dbBackend struct {
+		db       *gorm.DB
+		entities []model.Entity
+	}
+)
+
+func (b *remoteBackend) Specs(ctx context.Context) ([]*http.Spec, error) {
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, fmt.Sprintf("%s/_discover", strings.TrimSuffix(b.server, "/")), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := nethttp.DefaultClient.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if err = herror.IsError(res.StatusCode); err != nil {
+		return nil, err
+	}
+
+	bs, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	discovery := &http.Discovery{}
+	err = json.Unmarshal(bs, discovery)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return discovery.Specs, nil
+}
+
+func (b *remoteBackend) Read(ctx context.Context, name string, req *api.Read) (any, error) {
+	return b.client(name).Read(ctx, req)
+}
+
+func (b *remoteBackend) Search(ctx context.Context, name string, req *api.Search, scopes client.Scopes) (any, error) {
+	return b.client(name).Search(ctx, req, scopes)
+}
+
+func (b *remoteBackend) Create(ctx context.Context, name string, data map[string]any) (any, error) {
+	return b.client(name).Create(ctx, &data)
+}
+
+func (b *remoteBackend) Patch(ctx context.Context, name string, req *api.Patch, scopes client.Scopes) (any, error) {
+	return b.client(name).Patch(ctx, req, scopes)
+}
+
+func (b *remoteBackend) Delete(ctx context.Context, name string, id string, scopes client.Scopes) error {
+	return b.client(name).Delete(ctx, api.NewDelete(id, nil), scopes)
+}
+
+func (b *remoteBackend) client(name string) *client.Client[map[string]any] {
+	return client.New[map[string]any](b.server, name)
+}
+
+func (b *dbBackend) Specs(ctx context.Context) ([]*http.Spec, error) {
+	return slice.Map(b.entities, http.SpecFor), nil
+}
+
+func (b *dbBackend) Read(ctx context.Context, name string, req *api.Read) (any, error) {
+	store, _, err := b.storage(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Read(ctx, req)
+}
+
+func (b *dbBackend) Search(ctx context.Context, name string, req *api.Search, scopes client.Scopes) (any, error) {
+	store, entity, err := b.storage(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Hooks, err = hooks(entity, scopes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Search(ctx, req)
+}
+
+func (b *dbBackend) Create(ctx context.Context, name string, data map[string]any) (any, error) {
+	store, entity, err := b.storage(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// through json, so the entity is decoded like the api would
+	bs, err := json.Marshal(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	it := entity.Create()
+	err = json.Unmarshal(bs, it)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Create(ctx, api.NewCreate(it))
+}
+
+func (b *dbBackend) Patch(ctx context.Context, name string, req *api.Patch, scopes client.Scopes) (any, error) {
+	store, entity, err := b.storage(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Hooks, err = hooks(entity, scopes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store.Patch(ctx, req)
+}
+
+func (b *dbBackend) Delete(ctx context.Context, name string, id string, scopes client.Scopes) error {
+	store, entity, err := b.storage(name)
+
+	if err != nil {
+		return err
+	}
+
+	filters, err := hooks(entity, scopes)
+
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(ctx, api.NewDelete(id, filters))
+}
+
+func (b *dbBackend) storage(name string) (storage.Storage, model.Entity, error) {
+	for _, entity := range b.entities {
+		if entity.Name() == name {
+			store, err := storage.GormFactory(b.db, entity)
+			return store, entity, err
+		}
+	}
+
+	return nil, nil, fmt.Errorf("unknown entity %s", name)
+}
+
+// hooks turns scopes into the named filters of the entity
+func hooks(entity model.Entity, scopes client.Scopes) ([]model.Hook, error) {
+	result := make([]model.Hook, 0)
+
+	if len(scopes) == 0 {
+		return result, nil
+	}
+
+	scopeSupport, ok := entity.(model.ScopeSupport)
+
+	if !ok {
+		return nil, fmt.Errorf("%s has no named filters", entity.Name())
+	}
+
+	filters := scopeSupport.Scopes()
+
+	for name, params := range scopes {
+		filter := slice.Head(slice.Filter(filters, func(it *model.NamedFilter) bool {
+			return it.Name == name
+		}))
+
+		if filter == nil {
+			return nil, fmt.Errorf("%s has no named filter %s", entity.Name(), name)
+		}
+
+		result = append(result, filter.Scope(params))
+	}
+
+	return result, nil
+}
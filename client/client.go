@@ -0,0 +1,346 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+)
+
+type (
+	// Client talks to the api of T on a quickapi server
+	Client[T any] struct {
+		base   string
+		name   string
+		config *Config
+	}
+
+	Config struct {
+		HTTP   *http.Client
+		Params *openapi.Params // the query parameter names of the server
+		Header http.Header     // added to every request, ie Authorization
+	}
+
+	Option func(*Config)
+
+	// Scopes are the params of named filters by filter name, ie {"version": {"cas": "1"}}
+	Scopes map[string]map[string]string
+)
+
+// For creates a client for the entity T, served under base (ie http://localhost:8080/api)
+func For[T model.Entity](base string, options ...Option) *Client[T] {
+	var entity T
+	return New[T](base, entity.Name(), options...)
+}
+
+// New creates a client for the entity named name, decoded as T
+func New[T any](base, name string, options ...Option) *Client[T] {
+	config := &Config{
+		HTTP:   http.DefaultClient,
+		Params: openapi.DefaultParams(),
+		Header: make(http.Header),
+	}
+
+	slice.ForEach(options, func(option Option) {
+		option(config)
+	})
+
+	return &Client[T]{strings.TrimSuffix(base, "/"), name, config}
+}
+
+// WithHTTPClient sets the http client used for requests
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Config) {
+		c.HTTP = client
+	}
+}
+
+// WithParams sets the query parameter names, for servers not using the defaults
+func WithParams(params *openapi.Params) Option {
+	return func(c *Config) {
+		c.Params = params
+	}
+}
+
+// WithHeader adds a header to every request
+func WithHeader(key, value string) Option {
+	return func(c *Config) {
+		c.Header.Add(key, value)
+	}
+}
+
+func (c *Client[T]) Create(ctx context.Context, it *T) (*T, error) {
+	result := new(T)
+	err := c.do(ctx, http.MethodPost, "/", nil, it, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *Client[T]) Read(ctx context.Context, req *api.Read) (*T, error) {
+	query := url.Values{}
+	c.deepObject(query, c.config.Params.Preload, req.Preload)
+	c.expand(query, req.Expand)
+
+	result := new(T)
+	err := c.do(ctx, http.MethodGet, c.path(req.ID), query, nil, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Update updates the entity by id, the hooks of req are replaced by scopes.
+func (c *Client[T]) Update(ctx context.Context, req *api.Update, scopes Scopes) (*T, error) {
+	query := url.Values{}
+	c.scopes(query, scopes)
+
+	result := new(T)
+	err := c.do(ctx, http.MethodPut, c.path(req.ID), query, req.Entity, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Delete deletes the entity by id, the hooks of req are replaced by scopes.
+func (c *Client[T]) Delete(ctx context.Context, req *api.Delete, scopes Scopes) error {
+	query := url.Values{}
+	c.scopes(query, scopes)
+
+	return c.do(ctx, http.MethodDelete, c.path(req.ID), query, nil, nil)
+}
+
+// Search searches for entities, the hooks of req are replaced by scopes.
+// Skip and take are only sent when they're not 0, so the server defaults apply.
+func (c *Client[T]) Search(ctx context.Context, req *api.Search, scopes Scopes) ([]*T, error) {
+	query := c.Query(req, scopes)
+
+	result := make([]*T, 0)
+	err := c.do(ctx, http.MethodGet, "/", query, nil, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Patch updates the columns in data by id, the hooks of req are replaced by scopes.
+func (c *Client[T]) Patch(ctx context.Context, req *api.Patch, scopes Scopes) (*T, error) {
+	query := url.Values{}
+	c.deepObject(query, c.config.Params.Preload, req.Preload)
+	c.scopes(query, scopes)
+
+	result := new(T)
+	err := c.do(ctx, http.MethodPatch, c.path(req.ID), query, req.Data, result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Aggregate aggregates the entities by group, the hooks of req are replaced by scopes.
+func (c *Client[T]) Aggregate(ctx context.Context, req *api.Aggregate, scopes Scopes) ([]map[string]any, error) {
+	query := url.Values{}
+	c.deepObject(query, c.config.Params.Where, req.Where)
+	c.scopes(query, scopes)
+
+	if len(req.GroupBy) > 0 {
+		query.Set(c.config.Params.GroupBy, strings.Join(req.GroupBy, ","))
+	}
+
+	if req.Count {
+		query.Set("count", "true")
+	}
+
+	functions := map[string][]string{"sum": req.Sum, "avg": req.Avg, "min": req.Min, "max": req.Max}
+
+	for function, columns := range functions {
+		slice.ForEach(columns, func(column string) {
+			query.Set(fmt.Sprintf("%s[%s]", function, column), "")
+		})
+	}
+
+	result := make([]map[string]any, 0)
+	err := c.do(ctx, http.MethodGet, "/_aggregate", query, nil, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Facets counts the distinct values of each field, the hooks of req are replaced by scopes.
+func (c *Client[T]) Facets(ctx context.Context, req *api.Facets, scopes Scopes) (map[string][]*api.Facet, error) {
+	query := url.Values{}
+	c.deepObject(query, c.config.Params.Where, req.Where)
+	c.scopes(query, scopes)
+
+	slice.ForEach(req.Fields, func(field string) {
+		query.Add(c.config.Params.Field, field)
+	})
+
+	if req.Take != 0 {
+		query.Set(c.config.Params.Take, strconv.Itoa(req.Take))
+	}
+
+	if req.Query != "" {
+		query.Set(c.config.Params.Query, req.Query)
+	}
+
+	result := make(map[string][]*api.Facet)
+	err := c.do(ctx, http.MethodGet, "/_facets", query, nil, &result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Query encodes a search the way the server decodes it, ie where[name]=x&sort[age]=desc
+func (c *Client[T]) Query(req *api.Search, scopes Scopes) url.Values {
+	query := url.Values{}
+
+	if req.Skip != 0 {
+		query.Set(c.config.Params.Skip, strconv.Itoa(req.Skip))
+	}
+
+	if req.Take != 0 {
+		query.Set(c.config.Params.Take, strconv.Itoa(req.Take))
+	}
+
+	if req.Query != "" {
+		query.Set(c.config.Params.Query, req.Query)
+	}
+
+	c.deepObject(query, c.config.Params.Where, req.Where)
+	c.deepObject(query, c.config.Params.Sort, req.Sort)
+	c.deepObject(query, c.config.Params.Preload, req.Preload)
+	c.expand(query, req.Expand)
+	c.scopes(query, scopes)
+
+	return query
+}
+
+// expand encodes expansions like expand=pets&expand.pets.where[name]=x
+func (c *Client[T]) expand(query url.Values, expand []*api.Expand) {
+	for _, it := range expand {
+		prefix := fmt.Sprintf("%s.%s", c.config.Params.Expand, it.Relation)
+
+		query.Add(c.config.Params.Expand, it.Relation)
+		c.deepObject(query, prefix+".where", it.Where)
+		c.deepObject(query, prefix+".sort", it.Sort)
+
+		if it.Take != 0 {
+			query.Set(prefix+".take", strconv.Itoa(it.Take))
+		}
+	}
+}
+
+func (c *Client[T]) scopes(query url.Values, scopes Scopes) {
+	for name, params := range scopes {
+		c.deepObject(query, name, params)
+	}
+}
+
+func (c *Client[T]) deepObject(query url.Values, name string, values map[string]string) {
+	for key, value := range values {
+		query.Set(fmt.Sprintf("%s[%s]", name, key), value)
+	}
+}
+
+func (c *Client[T]) path(id string) string {
+	return fmt.Sprintf("/%s", url.PathEscape(id))
+}
+
+// do sends the request, responses that are not 2xx are returned as a herror.HttpError
+func (c *Client[T]) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	var reader io.Reader
+
+	if body != nil {
+		bs, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(bs)
+	}
+
+	target := fmt.Sprintf("%s/%s%s", c.base, c.name, path)
+
+	if len(query) > 0 {
+		target = fmt.Sprintf("%s?%s", target, query.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+
+	if err != nil {
+		return err
+	}
+
+	for key, values := range c.config.Header {
+		req.Header[key] = values
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.config.HTTP.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if len(bs) == 0 {
+			return herror.ErrorFromCode(res.StatusCode)
+		}
+
+		return herror.NewHttpError(res.StatusCode, string(bs))
+	}
+
+	if result == nil || len(bs) == 0 {
+		return nil
+	}
+
+	// numbers are kept as json.Number in untyped results, so large ids survive
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+
+	return decoder.Decode(result)
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/api"
+	quickhttp "github.com/Meduzz/quickapi/http"
+	"github.com/Meduzz/quickapi/openapi"
+	"github.com/Meduzz/quickapi/storage"
+	"github.com/gin-gonic/gin"
+)
+
+type pet struct {
+	ID      int64  `json:"id"`
+	Nick    string `json:"nick"`
+	Age     int    `json:"age"`
+	Species string `json:"species"`
+}
+
+func (pet) Name() string     { return "pets" }
+func (pet) Create() any      { return &pet{} }
+func (pet) CreateArray() any { return make([]*pet, 0) }
+
+func TestQuery(t *testing.T) {
+	custom := openapi.DefaultParams()
+	custom.Where = "filter"
+	custom.Take = "limit"
+
+	tests := []struct {
+		name    string
+		options []Option
+		search  *api.Search
+		scopes  Scopes
+		want    string
+	}{
+		{"empty", nil, &api.Search{}, nil, ""},
+		{"paging", nil, &api.Search{Skip: 10, Take: 5}, nil, "skip=10&take=5"},
+		{"where and sort", nil, &api.Search{Where: map[string]string{"name": "a b"}, Sort: map[string]string{"age": "desc"}}, nil, "sort%5Bage%5D=desc&where%5Bname%5D=a+b"},
+		{"preload", nil, &api.Search{Preload: map[string]string{"toys": ""}}, nil, "preload%5Btoys%5D="},
+		{"full text", nil, &api.Search{Query: "fluffy"}, nil, "q=fluffy"},
+		{"expand", nil, &api.Search{Expand: []*api.Expand{{Relation: "toys", Where: map[string]string{"kind": "ball"}, Take: 2}}}, nil, "expand=toys&expand.toys.take=2&expand.toys.where%5Bkind%5D=ball"},
+		{"scopes", nil, &api.Search{}, Scopes{"version": {"cas": "1"}}, "version%5Bcas%5D=1"},
+		{"custom params", []Option{WithParams(custom)}, &api.Search{Take: 5, Where: map[string]string{"name": "a"}}, nil, "filter%5Bname%5D=a&limit=5"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := For[pet]("http://localhost", test.options...).Query(test.search, test.scopes).Encode()
+
+			if got != test.want {
+				t.Fatalf("expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		is     error // nil when the message is the body
+	}{
+		{"not found", http.StatusNotFound, "", herror.ErrNotFound},
+		{"conflict", http.StatusConflict, "", herror.ErrConflict},
+		{"with a reason", http.StatusForbidden, "the pet is locked", nil},
+		{"server error", http.StatusInternalServerError, "", herror.ErrInternalError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			_, err := For[pet](server.URL).Read(context.Background(), &api.Read{ID: "1"})
+
+			if herror.CodeFromError(err) != test.status {
+				t.Fatalf("expected %d, got %v", test.status, err)
+			}
+
+			if test.is != nil && !errors.Is(err, test.is) {
+				t.Fatalf("expected %v, got %v", test.is, err)
+			}
+
+			httpError := herror.HttpError{}
+
+			if test.is == nil && (!errors.As(err, &httpError) || httpError.Message != test.body) {
+				t.Fatalf("expected the body as message, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHeadersAndPaths(t *testing.T) {
+	var got *http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := For[pet](server.URL+"/api/", WithHeader("Authorization", "Bearer x"))
+	err := client.Delete(context.Background(), &api.Delete{ID: "a/b"}, Scopes{"version": {"cas": "2"}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Method != http.MethodDelete || got.URL.EscapedPath() != "/api/pets/a%2Fb" || got.URL.RawQuery != (url.Values{"version[cas]": {"2"}}).Encode() {
+		t.Fatalf("expected DELETE /api/pets/a%%2Fb?version[cas]=2, got %s %s?%s", got.Method, got.URL.EscapedPath(), got.URL.RawQuery)
+	}
+
+	if got.Header.Get("Authorization") != "Bearer x" {
+		t.Fatalf("expected the authorization header, got %v", got.Header)
+	}
+}
+
+// TestServer checks that the server decodes what the client encodes
+func TestServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := quickhttp.DefaultConfig()
+	quickhttp.WithStorage(storage.MemoryFactory)(config)
+	engine := gin.New()
+	err := quickhttp.For(nil, engine.Group("/api"), config, pet{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(engine)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := For[pet](server.URL + "/api")
+
+	for _, it := range []*pet{{Nick: "rex", Age: 3, Species: "dog"}, {Nick: "tom", Age: 5, Species: "cat"}, {Nick: "fido", Age: 7, Species: "dog"}} {
+		_, err := client.Create(ctx, it)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dogs, err := client.Search(ctx, &api.Search{Where: map[string]string{"species": "dog"}, Sort: map[string]string{"age": "desc"}}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dogs) != 2 || dogs[0].Nick != "fido" || dogs[1].Nick != "rex" {
+		t.Fatalf("expected fido and rex, got %v", dogs)
+	}
+
+	paged, err := client.Search(ctx, &api.Search{Skip: 1, Take: 1, Sort: map[string]string{"age": "asc"}}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paged) != 1 || paged[0].Nick != "tom" {
+		t.Fatalf("expected tom, got %v", paged)
+	}
+
+	patched, err := client.Patch(ctx, &api.Patch{ID: "2", Data: map[string]any{"age": 6}}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if patched.Nick != "tom" || patched.Age != 6 {
+		t.Fatalf("expected tom to be 6, got %+v", patched)
+	}
+
+	err = client.Delete(ctx, &api.Delete{ID: "2"}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Read(ctx, &api.Read{ID: "2"})
+
+	if !errors.Is(err, herror.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	_, err = client.Read(ctx, &api.Read{ID: "x"})
+
+	if herror.CodeFromError(err) != http.StatusBadRequest {
+		t.Fatalf("expected bad request, got %v", err)
+	}
+}
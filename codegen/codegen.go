@@ -0,0 +1,186 @@
+package codegen
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+)
+
+type (
+	// entity is what the templates know about an entity
+	entity struct {
+		Name       string          // entity.Name
+		Ident      string          // entity.Name as an identifier, ie Persons
+		Type       reflect.Type    // the struct of the entity
+		Json       bool            // json kind, where and sort takes json paths
+		Operations map[string]bool // enabled operations
+		Columns    []*column       // columns that can be used in where and sort
+		Preloads   []*named        // preload aliases
+		Filters    []*named        // named filters
+	}
+
+	column struct {
+		Name  string       // column name
+		Ident string       // go field name
+		Type  reflect.Type // go type of the field
+	}
+
+	named struct {
+		Name  string
+		Ident string
+	}
+
+	// file is the data handed to the templates
+	file struct {
+		Package  string
+		Params   *openapi.Params
+		Entities []*entity
+	}
+)
+
+func newFile(pkg string, params *openapi.Params, entities []model.Entity) (*file, error) {
+	it := &file{
+		Package: pkg,
+		Params:  params,
+	}
+
+	for _, e := range entities {
+		described, err := describe(e)
+
+		if err != nil {
+			return nil, err
+		}
+
+		it.Entities = append(it.Entities, described)
+	}
+
+	return it, nil
+}
+
+func describe(e model.Entity) (*entity, error) {
+	t := reflect.TypeOf(e.Create())
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	it := &entity{
+		Name:       e.Name(),
+		Ident:      ident(e.Name()),
+		Type:       t,
+		Json:       model.KindOf(e) == model.KindJson,
+		Operations: make(map[string]bool),
+	}
+
+	for _, operation := range model.OperationsOf(e) {
+		it.Operations[string(operation)] = true
+	}
+
+	if discovery, ok := e.(model.PreloadDiscovery); ok {
+		for _, alias := range discovery.Preloads() {
+			it.Preloads = append(it.Preloads, &named{alias, ident(alias)})
+		}
+	}
+
+	if scopeSupport, ok := e.(model.ScopeSupport); ok {
+		for _, filter := range scopeSupport.Scopes() {
+			it.Filters = append(it.Filters, &named{filter.Name, ident(filter.Name)})
+		}
+	}
+
+	if it.Json {
+		return it, nil
+	}
+
+	sch, err := model.Schema(e)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, field := range sch.Fields {
+		if field.DBName == "" || field.StructField.Tag.Get("json") == "-" {
+			continue
+		}
+
+		it.Columns = append(it.Columns, &column{field.DBName, field.Name, field.FieldType})
+	}
+
+	return it, nil
+}
+
+// ident turns names like webhook_subscriptions into WebhookSubscriptions
+func ident(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	builder := &strings.Builder{}
+
+	for _, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		builder.WriteString(string(runes))
+	}
+
+	it := builder.String()
+
+	if it == "" || unicode.IsDigit([]rune(it)[0]) {
+		it = "X" + it
+	}
+
+	return it
+}
+
+// jsonTag returns the json name of the field, if it's hidden from json and if it's omitted when empty
+func jsonTag(field reflect.StructField) (string, bool, bool) {
+	tag, ok := field.Tag.Lookup("json")
+
+	if !ok {
+		return "", false, false
+	}
+
+	name, options, _ := strings.Cut(tag, ",")
+
+	if name == "-" && options == "" {
+		return "", true, false
+	}
+
+	return name, false, strings.Contains(options, "omitempty")
+}
+
+// fields returns the exported fields of t as encoding/json sees them, with embedded structs flattened
+func fields(t reflect.Type) []reflect.StructField {
+	result := make([]reflect.StructField, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, skip, _ := jsonTag(field)
+
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				result = append(result, fields(embedded)...)
+				continue
+			}
+		}
+
+		if field.IsExported() {
+			result = append(result, field)
+		}
+	}
+
+	return result
+}
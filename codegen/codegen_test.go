@@ -0,0 +1,205 @@
+package codegen
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+	"gorm.io/gorm"
+)
+
+type (
+	status string
+
+	// pet has most kinds of fields a client has to carry
+	pet struct {
+		ID       int64             `json:"id"`
+		Nick     string            `json:"nick"`
+		Status   status            `json:"status"`
+		Born     *time.Time        `json:"born,omitempty"`
+		Weight   float64           `json:"weight"`
+		Tags     []string          `json:"tags" gorm:"serializer:json"`
+		Extra    map[string]any    `json:"extra" gorm:"serializer:json"`
+		Raw      json.RawMessage   `json:"raw" gorm:"serializer:json"`
+		Owner    *owner            `json:"owner,omitempty"`
+		OwnerID  int64             `json:"-"`
+		Toys     []*toy            `json:"toys,omitempty"`
+		Counts   map[string]int    `json:"counts" gorm:"serializer:json"`
+		Secret   string            `json:"-"`
+		Settings map[string]string `json:"settings" gorm:"serializer:json"`
+	}
+
+	owner struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+
+	toy struct {
+		ID    int64  `json:"id"`
+		PetID int64  `json:"petId"`
+		Kind  string `json:"kind"`
+	}
+
+	// note is stored as json and can only be read
+	note struct {
+		ID   int64  `json:"id"`
+		Text string `json:"text"`
+		Pet  *pet   `json:"pet"`
+	}
+)
+
+func (pet) Name() string                                   { return "pets" }
+func (pet) Create() any                                    { return &pet{} }
+func (pet) CreateArray() any                               { return make([]*pet, 0) }
+func (pet) Preloads() []string                             { return []string{"toys", "owner"} }
+func (pet) Preload(string) map[string]*model.PreloadConfig { return nil }
+func (pet) Scopes() []*model.NamedFilter {
+	return []*model.NamedFilter{model.NewFilter("older_than", func(map[string]string) model.Hook {
+		return func(db *gorm.DB) *gorm.DB { return db }
+	})}
+}
+
+func (note) Name() string           { return "pet-notes" }
+func (note) Create() any            { return &note{} }
+func (note) CreateArray() any       { return make([]*note, 0) }
+func (note) Kind() model.EntityKind { return model.KindJson }
+func (note) Operations() []model.Operation {
+	return []model.Operation{model.OperationRead, model.OperationSearch}
+}
+
+func TestGoCompiles(t *testing.T) {
+	source, err := Go("petclient", openapi.DefaultParams(), pet{}, note{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "client.go", source, 0)
+
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, source)
+	}
+
+	config := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := config.Check("petclient", fset, []*ast.File{file}, nil)
+
+	if err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s", err, source)
+	}
+
+	for _, name := range []string{"Client", "Error", "New", "IsNotFound", "pet", "owner", "toy", "note", "PetsQuery", "PetNotesQuery"} {
+		if pkg.Scope().Lookup(name) == nil {
+			t.Errorf("expected %s to be generated", name)
+		}
+	}
+
+	raw, _, _ := types.LookupFieldOrMethod(pkg.Scope().Lookup("pet").Type(), false, pkg, "Raw")
+
+	if raw == nil || raw.Type().String() != "encoding/json.RawMessage" {
+		t.Errorf("expected pet.Raw to be kept raw, got %v", raw)
+	}
+
+	query := types.NewPointer(pkg.Scope().Lookup("PetsQuery").Type())
+
+	for _, name := range []string{"WhereNick", "SortWeight", "PreloadToys", "FilterOlderThan"} {
+		if method, _, _ := types.LookupFieldOrMethod(query, true, pkg, name); method == nil {
+			t.Errorf("expected PetsQuery.%s to be generated", name)
+		}
+	}
+}
+
+// balanced reports if the brackets of source are balanced, strings, template literals and comments are skipped
+func balanced(source string) bool {
+	pairs := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	stack := make([]rune, 0)
+	quote := rune(0)
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote != 0:
+			if r == '\\' {
+				i++
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := strings.Index(string(runes[i:]), "*/")
+
+			if end < 0 {
+				return false
+			}
+
+			i += len([]rune(string(runes[i:])[:end])) + 1
+		case r == '(' || r == '[' || r == '{':
+			stack = append(stack, r)
+		case r == ')' || r == ']' || r == '}':
+			if len(stack) == 0 || stack[len(stack)-1] != pairs[r] {
+				return false
+			}
+
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	return len(stack) == 0 && quote == 0
+}
+
+func TestTypeScript(t *testing.T) {
+	source, err := TypeScript(openapi.DefaultParams(), pet{}, note{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := string(source)
+
+	if strings.Contains(ts, "<no value>") || !balanced(ts) {
+		t.Fatalf("expected valid typescript, got\n%s", ts)
+	}
+
+	for _, want := range []string{
+		"export interface pet {",
+		`"born"?: string | null;`,
+		`"raw": unknown;`,
+		`"owner"?: owner | null;`,
+		`"toys"?: Array<toy>;`,
+		`"counts": Record<string, number>;`,
+		`export type PetsColumn = "id" | "nick" | "status" | "born" | "weight" | "tags" | "extra" | "raw" | "counts" | "settings";`,
+		`"weight"?: number;`,
+		`"toys"?: string;`,
+		`"older_than"?: Record<string, string>;`,
+		"export type PetNotesColumn = string;",
+		`get "pet-notes"(): PetNotesClient {`,
+		"export class PetsClient {",
+		"create(it: pet): Promise<pet> {",
+		"search(query?: PetNotesQuery): Promise<Array<note>> {",
+	} {
+		if !strings.Contains(ts, want) {
+			t.Errorf("expected %s in\n%s", want, ts)
+		}
+	}
+
+	for _, hidden := range []string{`"secret"`, `"ownerID"`, "create(it: note)", "update(id: string | number, it: note"} {
+		if strings.Contains(ts, hidden) {
+			t.Errorf("expected no %s", hidden)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package codegen
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"reflect"
+	"text/template"
+	"time"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+)
+
+type (
+	goStruct struct {
+		Name   string
+		Fields []*goField
+	}
+
+	goField struct {
+		Name string
+		Type string
+		Tag  string
+	}
+
+	// golang keeps track of the structs referenced by the entities
+	golang struct {
+		names   map[reflect.Type]string
+		structs []*goStruct
+		time    bool
+	}
+)
+
+var (
+	//go:embed templates/golang.tmpl
+	golangTemplate string
+
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Go generates a typed client package for the entities, params are the query parameter names of the server.
+func Go(pkg string, params *openapi.Params, entities ...model.Entity) ([]byte, error) {
+	data, err := newFile(pkg, params, entities)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g := &golang{names: make(map[reflect.Type]string)}
+
+	for _, it := range data.Entities {
+		g.typeOf(it.Type)
+	}
+
+	funcs := template.FuncMap{
+		"structs":   func() []*goStruct { return g.structs },
+		"imports":   func() bool { return g.time },
+		"goType":    g.typeOf,
+		"whereType": whereType,
+	}
+
+	tmpl, err := template.New("golang").Funcs(funcs).Parse(golangTemplate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	err = tmpl.Execute(buffer, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := format.Source(buffer.Bytes())
+
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+
+	return source, nil
+}
+
+// typeOf returns the go type of t in the generated code, named structs are generated as well
+func (g *golang) typeOf(t reflect.Type) string {
+	if t == timeType {
+		g.time = true
+		return "time.Time"
+	}
+
+	if reflect.PointerTo(t).Implements(marshalerType) {
+		// no idea what it turns into
+		return "json.RawMessage"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + g.typeOf(t.Elem())
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "[]byte"
+		}
+
+		return "[]" + g.typeOf(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), g.typeOf(t.Elem()))
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s", g.typeOf(t.Key()), g.typeOf(t.Elem()))
+	case reflect.Interface:
+		return "any"
+	}
+
+	if t.Kind() == reflect.Struct {
+		if t.Name() == "" {
+			return "map[string]any"
+		}
+
+		return g.structOf(t)
+	}
+
+	// basic kinds, named types are replaced by their kind
+	return t.Kind().String()
+}
+
+func (g *golang) structOf(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+
+	// two structs with the same name from different packages
+	for g.taken(name) {
+		name = name + "_"
+	}
+
+	g.names[t] = name
+	it := &goStruct{Name: name}
+	g.structs = append(g.structs, it)
+
+	for _, field := range fields(t) {
+		tag := ""
+
+		if value, ok := field.Tag.Lookup("json"); ok {
+			tag = fmt.Sprintf("json:%q", value)
+		}
+
+		it.Fields = append(it.Fields, &goField{field.Name, g.typeOf(field.Type), tag})
+	}
+
+	return name
+}
+
+func (g *golang) taken(name string) bool {
+	for _, it := range g.structs {
+		if it.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// whereType returns the go type used in typed where builders, or "" when the column can't be compared as a string
+func whereType(c *column) string {
+	t := c.Type
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return t.Kind().String()
+	default:
+		return ""
+	}
+}
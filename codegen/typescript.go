@@ -0,0 +1,175 @@
+package codegen
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"reflect"
+	"text/template"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+)
+
+type (
+	tsInterface struct {
+		Name   string
+		Fields []*tsField
+	}
+
+	tsField struct {
+		Name     string
+		Type     string
+		Optional bool
+	}
+
+	// typescript keeps track of the interfaces referenced by the entities
+	typescript struct {
+		names      map[reflect.Type]string
+		interfaces []*tsInterface
+	}
+)
+
+//go:embed templates/typescript.tmpl
+var typescriptTemplate string
+
+// TypeScript generates interfaces and a fetch based client for the entities, params are the query parameter names of the server.
+func TypeScript(params *openapi.Params, entities ...model.Entity) ([]byte, error) {
+	data, err := newFile("", params, entities)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &typescript{names: make(map[reflect.Type]string)}
+
+	for _, it := range data.Entities {
+		ts.typeOf(it.Type)
+	}
+
+	funcs := template.FuncMap{
+		"interfaces": func() []*tsInterface { return ts.interfaces },
+		"tsType":     ts.typeOf,
+		"columnType": columnType,
+	}
+
+	tmpl, err := template.New("typescript").Funcs(funcs).Parse(typescriptTemplate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := &bytes.Buffer{}
+	err = tmpl.Execute(buffer, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// typeOf returns the typescript type of t, named structs are generated as interfaces.
+// Pointers are only nullable as fields.
+func (ts *typescript) typeOf(t reflect.Type) string {
+	if t == timeType {
+		return "string"
+	}
+
+	if reflect.PointerTo(t).Implements(marshalerType) {
+		// no idea what it turns into
+		return "unknown"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return ts.typeOf(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// base64
+			return "string"
+		}
+
+		return fmt.Sprintf("Array<%s>", ts.typeOf(t.Elem()))
+	case reflect.Map:
+		return fmt.Sprintf("Record<string, %s>", ts.typeOf(t.Elem()))
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return "Record<string, unknown>"
+		}
+
+		return ts.interfaceOf(t)
+	default:
+		return "unknown"
+	}
+}
+
+func (ts *typescript) interfaceOf(t reflect.Type) string {
+	if name, ok := ts.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+
+	// two structs with the same name from different packages
+	for ts.taken(name) {
+		name = name + "_"
+	}
+
+	ts.names[t] = name
+	it := &tsInterface{Name: name}
+	ts.interfaces = append(ts.interfaces, it)
+
+	for _, field := range fields(t) {
+		jsonName, _, omitempty := jsonTag(field)
+
+		if jsonName == "" {
+			jsonName = field.Name
+		}
+
+		kind := ts.typeOf(field.Type)
+
+		if field.Type.Kind() == reflect.Pointer {
+			kind = fmt.Sprintf("%s | null", kind)
+		}
+
+		it.Fields = append(it.Fields, &tsField{fmt.Sprintf("%q", jsonName), kind, omitempty})
+	}
+
+	return name
+}
+
+func (ts *typescript) taken(name string) bool {
+	for _, it := range ts.interfaces {
+		if it.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// columnType is the type of where values of the column
+func columnType(c *column) string {
+	switch whereType(c) {
+	case "":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "string":
+		return "string"
+	default:
+		return "number"
+	}
+}
@@ -0,0 +1,557 @@
+package quickapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/client"
+	"github.com/Meduzz/quickapi/http"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+type (
+	// dataFlags are shared by all data commands
+	dataFlags struct {
+		server string
+		dsn    string
+		output string
+	}
+)
+
+// Commands returns a root command with start, generate and commands to inspect and change data.
+// The data commands talk to a running server with --server, or directly to db without lifecycle hooks and events.
+// When db is nil, start and the data commands open it from --dsn (or QUICKAPI_DSN).
+func Commands(db *gorm.DB, entities ...model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+	flags := &dataFlags{}
+
+	cmd.Use = "quickapi"
+	cmd.Short = "quickapi server and client"
+	cmd.SilenceUsage = true
+
+	cmd.PersistentFlags().StringVar(&flags.server, "server", "", "url of a running server (ie http://localhost:8080), defaults to the db")
+	cmd.PersistentFlags().StringVar(&flags.dsn, "dsn", os.Getenv(envName("dsn")), "db to open when none was given, ie sqlite://test.db")
+
+	cmd.AddCommand(GinStarter(db, entities...))
+	cmd.AddCommand(GenerateCommand(entities...))
+	cmd.AddCommand(migrateCommand(flags, db, entities))
+	cmd.AddCommand(flags.printing(entitiesCommand(flags, db, entities)))
+	cmd.AddCommand(flags.printing(getCommand(flags, db, entities)))
+	cmd.AddCommand(flags.printing(listCommand(flags, db, entities)))
+	cmd.AddCommand(flags.printing(createCommand(flags, db, entities)))
+	cmd.AddCommand(flags.printing(patchCommand(flags, db, entities)))
+	cmd.AddCommand(deleteCommand(flags, db, entities))
+
+	return cmd
+}
+
+// printing adds --output (-o) to a command that prints data. It's not a persistent flag,
+// since generate has an -o (file) of its own.
+func (f *dataFlags) printing(cmd *cobra.Command) *cobra.Command {
+	cmd.Flags().StringVarP(&f.output, "output", "o", "table", "output format, table, json or yaml")
+	return cmd
+}
+
+func entitiesCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "entities"
+	cmd.Short = "list the entities and what can be done with them"
+	cmd.Args = cobra.NoArgs
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, err := flags.backend(db, entities)
+
+		if err != nil {
+			return err
+		}
+
+		specs, err := b.Specs(cmd.Context())
+
+		if err != nil {
+			return err
+		}
+
+		if flags.output != "table" {
+			return flags.print(cmd.OutOrStdout(), nil, specs)
+		}
+
+		rows := make([]map[string]any, 0)
+
+		for _, spec := range specs {
+			rows = append(rows, map[string]any{
+				"name":       spec.Name,
+				"kind":       spec.Kind,
+				"operations": spec.Operations,
+				"filters":    spec.Filters,
+				"preloads":   spec.Preloads,
+			})
+		}
+
+		return table(cmd.OutOrStdout(), []string{"name", "kind", "operations", "filters", "preloads"}, rows)
+	}
+
+	return cmd
+}
+
+func getCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "get <entity> <id>"
+	cmd.Short = "read an entity by id"
+	cmd.Args = cobra.ExactArgs(2)
+
+	preload := cmd.Flags().StringArray("preload", nil, "preload alias=condition, repeatable")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, spec, err := flags.resolve(cmd.Context(), db, entities, args[0], model.OperationRead)
+
+		if err != nil {
+			return err
+		}
+
+		preloads, err := pairs(*preload)
+
+		if err != nil {
+			return err
+		}
+
+		it, err := b.Read(cmd.Context(), spec.Name, api.NewRead(args[1], preloads))
+
+		if err != nil {
+			return err
+		}
+
+		return flags.print(cmd.OutOrStdout(), spec, it)
+	}
+
+	return cmd
+}
+
+func listCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "list <entity>"
+	cmd.Short = "search for entities"
+	cmd.Args = cobra.ExactArgs(1)
+
+	where := cmd.Flags().StringArray("where", nil, "where column=value, repeatable")
+	sorting := cmd.Flags().StringArray("sort", nil, "sort column=asc|desc, repeatable")
+	preload := cmd.Flags().StringArray("preload", nil, "preload alias=condition, repeatable")
+	scope := cmd.Flags().StringArray("scope", nil, "named filter param, filter.key=value, repeatable")
+	skip := cmd.Flags().Int("skip", 0, "number of results to skip")
+	take := cmd.Flags().Int("take", 25, "max number of results")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, spec, err := flags.resolve(cmd.Context(), db, entities, args[0], model.OperationSearch)
+
+		if err != nil {
+			return err
+		}
+
+		req := api.NewSearch(*skip, *take, nil, nil, nil, nil)
+
+		if req.Where, err = pairs(*where); err != nil {
+			return err
+		}
+
+		if req.Sort, err = pairs(*sorting); err != nil {
+			return err
+		}
+
+		if req.Preload, err = pairs(*preload); err != nil {
+			return err
+		}
+
+		scopes, err := scoped(*scope)
+
+		if err != nil {
+			return err
+		}
+
+		it, err := b.Search(cmd.Context(), spec.Name, req, scopes)
+
+		if err != nil {
+			return err
+		}
+
+		return flags.print(cmd.OutOrStdout(), spec, it)
+	}
+
+	return cmd
+}
+
+func createCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "create <entity> [json]"
+	cmd.Short = "create an entity from json, read from stdin when not provided"
+	cmd.Args = cobra.RangeArgs(1, 2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, spec, err := flags.resolve(cmd.Context(), db, entities, args[0], model.OperationCreate)
+
+		if err != nil {
+			return err
+		}
+
+		data, err := document(cmd.InOrStdin(), args[1:])
+
+		if err != nil {
+			return err
+		}
+
+		it, err := b.Create(cmd.Context(), spec.Name, data)
+
+		if err != nil {
+			return err
+		}
+
+		return flags.print(cmd.OutOrStdout(), spec, it)
+	}
+
+	return cmd
+}
+
+func patchCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "patch <entity> <id> [json]"
+	cmd.Short = "update columns of an entity from json, read from stdin when not provided"
+	cmd.Args = cobra.RangeArgs(2, 3)
+
+	scope := cmd.Flags().StringArray("scope", nil, "named filter param, filter.key=value, repeatable")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, spec, err := flags.resolve(cmd.Context(), db, entities, args[0], model.OperationPatch)
+
+		if err != nil {
+			return err
+		}
+
+		data, err := document(cmd.InOrStdin(), args[2:])
+
+		if err != nil {
+			return err
+		}
+
+		scopes, err := scoped(*scope)
+
+		if err != nil {
+			return err
+		}
+
+		it, err := b.Patch(cmd.Context(), spec.Name, api.NewPatch(args[1], data, nil, nil), scopes)
+
+		if err != nil {
+			return err
+		}
+
+		return flags.print(cmd.OutOrStdout(), spec, it)
+	}
+
+	return cmd
+}
+
+func deleteCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "delete <entity> <id>"
+	cmd.Short = "delete an entity by id"
+	cmd.Args = cobra.ExactArgs(2)
+
+	scope := cmd.Flags().StringArray("scope", nil, "named filter param, filter.key=value, repeatable")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		b, spec, err := flags.resolve(cmd.Context(), db, entities, args[0], model.OperationDelete)
+
+		if err != nil {
+			return err
+		}
+
+		scopes, err := scoped(*scope)
+
+		if err != nil {
+			return err
+		}
+
+		return b.Delete(cmd.Context(), spec.Name, args[1], scopes)
+	}
+
+	return cmd
+}
+
+func (f *dataFlags) backend(db *gorm.DB, entities []model.Entity) (backend, error) {
+	if f.server != "" {
+		return &remoteBackend{f.server}, nil
+	}
+
+	db, err := f.database(db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dbBackend{db, entities}, nil
+}
+
+// database is db, or the db of --dsn when db is nil
+func (f *dataFlags) database(db *gorm.DB) (*gorm.DB, error) {
+	if db != nil {
+		return db, nil
+	}
+
+	if f.dsn == "" {
+		return nil, fmt.Errorf("no db available, use --server or --dsn")
+	}
+
+	return Open(&Database{DSN: f.dsn})
+}
+
+// resolve finds the backend and the spec of the named entity, and checks that the operation is enabled
+func (f *dataFlags) resolve(ctx context.Context, db *gorm.DB, entities []model.Entity, name string, operation model.Operation) (backend, *http.Spec, error) {
+	b, err := f.backend(db, entities)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	specs, err := b.Specs(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, spec := range specs {
+		if spec.Name != name {
+			continue
+		}
+
+		for _, it := range spec.Operations {
+			if it == operation {
+				return b, spec, nil
+			}
+		}
+
+		return nil, nil, fmt.Errorf("%s is not enabled for %s", operation, name)
+	}
+
+	return nil, nil, fmt.Errorf("unknown entity %s", name)
+}
+
+// print writes data in the output format, tables have a column per field of the entity
+func (f *dataFlags) print(out io.Writer, spec *http.Spec, data any) error {
+	switch f.output {
+	case "json":
+		bs, err := json.MarshalIndent(data, "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(bs))
+		return err
+	case "yaml":
+		// through json so the json names are used
+		generic, err := generic(data)
+
+		if err != nil {
+			return err
+		}
+
+		bs, err := yaml.Marshal(numbers(generic))
+
+		if err != nil {
+			return err
+		}
+
+		_, err = out.Write(bs)
+		return err
+	case "table":
+		generic, err := generic(data)
+
+		if err != nil {
+			return err
+		}
+
+		rows := make([]map[string]any, 0)
+
+		switch it := generic.(type) {
+		case []any:
+			for _, row := range it {
+				if m, ok := row.(map[string]any); ok {
+					rows = append(rows, m)
+				}
+			}
+		case map[string]any:
+			rows = append(rows, it)
+		}
+
+		return table(out, columns(spec, rows), rows)
+	default:
+		return fmt.Errorf("unknown output format %s", f.output)
+	}
+}
+
+// columns are the non struct fields of the entity, or the keys of the first row for json entities
+func columns(spec *http.Spec, rows []map[string]any) []string {
+	result := make([]string, 0)
+
+	if spec != nil && spec.Entity != nil && spec.Kind != string(model.KindJson) {
+		for _, field := range spec.Entity.Fields {
+			if field.Type != "struct" && !field.Array && !field.Map {
+				result = append(result, field.Name)
+			}
+		}
+
+		return result
+	}
+
+	if len(rows) > 0 {
+		for key := range rows[0] {
+			result = append(result, key)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+func table(out io.Writer, columns []string, rows []map[string]any) error {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.ToUpper(strings.Join(columns, "\t")))
+
+	for _, row := range rows {
+		values := make([]string, 0, len(columns))
+
+		for _, column := range columns {
+			value, ok := row[column]
+
+			if !ok || value == nil {
+				values = append(values, "")
+				continue
+			}
+
+			values = append(values, fmt.Sprint(value))
+		}
+
+		fmt.Fprintln(writer, strings.Join(values, "\t"))
+	}
+
+	return writer.Flush()
+}
+
+// generic turns data into maps and slices, through json
+func generic(data any) (any, error) {
+	bs, err := json.Marshal(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var it any
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	decoder.UseNumber()
+	err = decoder.Decode(&it)
+
+	return it, err
+}
+
+// numbers turns json.Number into int64 or float64, since yaml would quote them
+func numbers(data any) any {
+	switch it := data.(type) {
+	case json.Number:
+		if value, err := it.Int64(); err == nil {
+			return value
+		}
+
+		value, _ := it.Float64()
+		return value
+	case []any:
+		for i, value := range it {
+			it[i] = numbers(value)
+		}
+	case map[string]any:
+		for key, value := range it {
+			it[key] = numbers(value)
+		}
+	}
+
+	return data
+}
+
+// document reads a json object from the args, or from in when there are none
+func document(in io.Reader, args []string) (map[string]any, error) {
+	var bs []byte
+	var err error
+
+	if len(args) > 0 {
+		bs = []byte(args[0])
+	} else {
+		bs, err = io.ReadAll(in)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data := make(map[string]any)
+	err = json.Unmarshal(bs, &data)
+
+	return data, err
+}
+
+// pairs turns key=value flags into a map
+func pairs(values []string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	for _, value := range values {
+		key, it, ok := strings.Cut(value, "=")
+
+		if !ok {
+			return nil, fmt.Errorf("expected key=value, got %s", value)
+		}
+
+		result[key] = it
+	}
+
+	return result, nil
+}
+
+// scoped turns filter.key=value flags into scopes
+func scoped(values []string) (client.Scopes, error) {
+	all, err := pairs(values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(client.Scopes)
+
+	for key, value := range all {
+		filter, param, ok := strings.Cut(key, ".")
+
+		if !ok {
+			return nil, fmt.Errorf("expected filter.key=value, got %s", key)
+		}
+
+		if result[filter] == nil {
+			result[filter] = make(map[string]string)
+		}
+
+		result[filter][param] = value
+	}
+
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package quickapi
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+type thing struct {
+	ID   int64  `json:"id"`
+	Text string `json:"text"`
+}
+
+func (thing) Name() string     { return "things" }
+func (thing) Create() any      { return &thing{} }
+func (thing) CreateArray() any { return make([]*thing, 0) }
+
+// TestCommandTree parses the flags of every command, flags that clash panic
+func TestCommandTree(t *testing.T) {
+	root := Commands(nil, thing{})
+	commands := []*cobra.Command{root}
+
+	for len(commands) > 0 {
+		cmd := commands[0]
+		commands = append(commands[1:], cmd.Commands()...)
+
+		t.Run(cmd.CommandPath(), func(t *testing.T) {
+			args := append(path(cmd)[1:], "--help")
+
+			root.SetArgs(args)
+			root.SetOut(io.Discard)
+			root.SetErr(io.Discard)
+
+			err := root.Execute()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestOutputShorthands(t *testing.T) {
+	root := Commands(nil, thing{})
+
+	for _, args := range [][]string{{"list", "things"}, {"generate", "go"}, {"migrate", "status"}} {
+		cmd, _, err := root.Find(args)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cmd.Flags().ShorthandLookup("o") == nil {
+			t.Errorf("%s has no -o", cmd.CommandPath())
+		}
+	}
+}
+
+func path(cmd *cobra.Command) []string {
+	if !cmd.HasParent() {
+		return []string{cmd.Name()}
+	}
+
+	return append(path(cmd.Parent()), cmd.Name())
+}
@@ -0,0 +1,138 @@
+package quickapi
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// unreachable opens like a lazy driver would, but the connection is gone by the ping
+type unreachable struct {
+	gorm.Dialector
+}
+
+func (u unreachable) Initialize(db *gorm.DB) error {
+	err := u.Dialector.Initialize(db)
+
+	if err != nil {
+		return err
+	}
+
+	return db.ConnPool.(*sql.DB).Close()
+}
+
+// flaky registers a dialect under scheme that can't open the db until the attempt after failures,
+// the returned func tells how many attempts were made
+func flaky(t *testing.T, scheme string, failures int) func() int {
+	missing := filepath.Join(t.TempDir(), "missing", "test.db")
+	attempts := 0
+
+	RegisterDialect(scheme, func(dsn string) gorm.Dialector {
+		attempts++
+
+		if attempts <= failures {
+			return sqlite.Open(missing)
+		}
+
+		return sqlite.Open(":memory:")
+	})
+
+	return func() int {
+		return attempts
+	}
+}
+
+func TestOpenRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		retries  int
+		attempts int
+		opened   bool
+	}{
+		{"first attempt", 0, 0, 1, true},
+		{"no retries", 1, 0, 1, false},
+		{"after a retry", 2, 3, 3, true},
+		{"out of retries", 5, 2, 3, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempts := flaky(t, "flaky", test.failures)
+			settings := &Database{
+				DSN:        "flaky://test",
+				MaxOpen:    3,
+				Retries:    test.retries,
+				RetryDelay: Duration(time.Millisecond),
+			}
+
+			db, err := Open(settings)
+
+			if attempts() != test.attempts {
+				t.Fatalf("expected %d attempts, got %d", test.attempts, attempts())
+			}
+
+			if !test.opened {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pool, err := db.DB()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer pool.Close()
+
+			if pool.Stats().MaxOpenConnections != 3 {
+				t.Fatalf("expected max 3 open connections, got %d", pool.Stats().MaxOpenConnections)
+			}
+		})
+	}
+}
+
+func TestOpenPings(t *testing.T) {
+	RegisterDialect("unreachable", func(dsn string) gorm.Dialector {
+		return unreachable{sqlite.Open(":memory:")}
+	})
+
+	_, err := Open(&Database{DSN: "unreachable://test"})
+
+	if err == nil {
+		t.Fatal("expected the ping to fail")
+	}
+}
+
+func TestOpenRejectsDSN(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *Database
+	}{
+		{"no settings", nil},
+		{"no dsn", &Database{}},
+		{"no scheme", &Database{DSN: "test.db"}},
+		{"unknown dialect", &Database{DSN: "nope://test"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Open(test.settings)
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
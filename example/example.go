@@ -2,6 +2,7 @@ package main
 
 import (
 	"math/rand"
+	"sort"
 	"strconv"
 
 	"github.com/Meduzz/quickapi"
@@ -28,11 +29,12 @@ type (
 )
 
 var (
-	_      model.Entity         = Person{}
-	_      model.Entity         = Pet{}
-	_      model.PreloadSupport = Person{}
-	_      model.ScopeSupport   = Person{}
-	random                      = rand.New(rand.NewSource(int64(rand.Int31())))
+	_      model.Entity           = Person{}
+	_      model.Entity           = Pet{}
+	_      model.PreloadSupport   = Person{}
+	_      model.PreloadDiscovery = Person{}
+	_      model.ScopeSupport     = Person{}
+	random                        = rand.New(rand.NewSource(int64(rand.Int31())))
 )
 
 func (Person) Name() string {
@@ -57,6 +59,18 @@ func (Person) Preload(key string) map[string]*model.PreloadConfig {
 	return it
 }
 
+func (Person) Preloads() []string {
+	aliases := make([]string, 0)
+
+	for alias := range preload {
+		aliases = append(aliases, alias)
+	}
+
+	sort.Strings(aliases)
+
+	return aliases
+}
+
 // implement optimistic locking..ish
 func (Person) Scopes() []*model.NamedFilter {
 	return []*model.NamedFilter{
@@ -108,7 +122,8 @@ func main() {
 		panic(err)
 	}
 
-	start := quickapi.GinStarter(db, Person{}, Pet{})
+	// start, generate and data commands, ie: go run . list persons --server http://localhost:8080
+	start := quickapi.Commands(db, Person{}, Pet{})
 
 	// model.NewEntity[Person]("person", personPreload, model.NewFilter("asdf", preloadPets())),
 
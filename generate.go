@@ -0,0 +1,104 @@
+package quickapi
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/Meduzz/quickapi/codegen"
+	"github.com/Meduzz/quickapi/http"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+	"github.com/spf13/cobra"
+)
+
+// GenerateCommand returns a cobra command that generates artifacts from the entities.
+func GenerateCommand(entities ...model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "generate"
+	cmd.Short = "generate documents and code from the entities"
+
+	cmd.AddCommand(openApiCommand(entities...))
+	cmd.AddCommand(goCommand(entities...))
+	cmd.AddCommand(typeScriptCommand(entities...))
+
+	return cmd
+}
+
+func openApiCommand(entities ...model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "openapi"
+	cmd.Short = "generate an OpenAPI 3.1 document"
+
+	out := cmd.Flags().StringP("out", "o", "", "file to write to, defaults to stdout")
+	basePath := cmd.Flags().String("base-path", "/", "path the api is served under")
+	title := cmd.Flags().String("title", openapi.DefaultInfo().Title, "title of the api")
+	version := cmd.Flags().String("version", openapi.DefaultInfo().Version, "version of the api")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		doc := http.OpenApi(&openapi.Info{Title: *title, Version: *version}, *basePath, entities...)
+		bs, err := json.MarshalIndent(doc, "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		return write(*out, bs)
+	}
+
+	return cmd
+}
+
+func goCommand(entities ...model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "go"
+	cmd.Short = "generate a typed go client package"
+
+	out := cmd.Flags().StringP("out", "o", "", "file to write to, defaults to stdout")
+	pkg := cmd.Flags().String("package", "client", "package of the generated code")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bs, err := codegen.Go(*pkg, http.DefaultParams(), entities...)
+
+		if err != nil {
+			return err
+		}
+
+		return write(*out, bs)
+	}
+
+	return cmd
+}
+
+func typeScriptCommand(entities ...model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "ts"
+	cmd.Short = "generate typescript interfaces and a fetch based client"
+
+	out := cmd.Flags().StringP("out", "o", "", "file to write to, defaults to stdout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bs, err := codegen.TypeScript(http.DefaultParams(), entities...)
+
+		if err != nil {
+			return err
+		}
+
+		return write(*out, bs)
+	}
+
+	return cmd
+}
+
+// write writes bs to the file, or stdout when file is empty
+func write(file string, bs []byte) error {
+	if file == "" {
+		_, err := os.Stdout.Write(bs)
+		return err
+	}
+
+	return os.WriteFile(file, bs, 0644)
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/gin-gonic/gin"
+	gormschema "gorm.io/gorm/schema"
+)
+
+var numeric = []gormschema.DataType{gormschema.Int, gormschema.Uint, gormschema.Float}
+
+// Aggregate responds with the aggregates of the rows matching where and the named filters, by group
+func (r *router) Aggregate(ctx *gin.Context) {
+	where := r.config.Where(ctx)
+	req := r.config.Aggregate(ctx)
+	err := r.resolveAggregate(req, where)
+
+	if err != nil {
+		println("resolving aggregate threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := r.hookContext(ctx, model.OperationSearch)
+	defer cancel()
+
+	err = r.beforeSearch(hctx, where)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
+	req.Where = where
+	req.Hooks = CreateHooks(r.entity, ctx)
+
+	rows, err := r.storage.Aggregate(hctx, req)
+
+	if err != nil {
+		println("aggregating data threw error", err.Error())
+
+		if strings.Contains(err.Error(), "syntax error") || strings.Contains(err.Error(), "no such column") {
+			ctx.AbortWithStatus(400)
+			return
+		}
+
+		code := codeFromError(err)
+
+		ctx.AbortWithStatus(code)
+		return
+	}
+
+	ctx.JSON(200, rows)
+}
+
+// resolveAggregate turns field names into columns and checks that they exist, sum and avg only take numbers.
+// Without aggregates the rows are counted. Json entities are left to the storage.
+func (r *router) resolveAggregate(req *api.Aggregate, where map[string]string) error {
+	if !req.Count && len(req.Sum)+len(req.Avg)+len(req.Min)+len(req.Max) == 0 {
+		req.Count = true
+	}
+
+	if model.KindOf(r.entity) != model.KindNormal {
+		return nil
+	}
+
+	sch, err := model.Schema(r.entity)
+
+	if err != nil {
+		return err
+	}
+
+	err = whereColumns(sch, where)
+
+	if err != nil {
+		return err
+	}
+
+	columns := func(names []string, types ...gormschema.DataType) ([]string, error) {
+		result := make([]string, 0, len(names))
+
+		for _, name := range names {
+			field := sch.LookUpField(name)
+
+			if field == nil || field.DBName == "" {
+				return nil, fmt.Errorf("unknown column %s in %s", name, sch.Table)
+			}
+
+			if len(types) > 0 && !slices.Contains(types, field.DataType) {
+				return nil, fmt.Errorf("column %s in %s is not a number", name, sch.Table)
+			}
+
+			result = append(result, field.DBName)
+		}
+
+		return result, nil
+	}
+
+	if req.GroupBy, err = columns(req.GroupBy); err != nil {
+		return err
+	}
+
+	if req.Sum, err = columns(req.Sum, numeric...); err != nil {
+		return err
+	}
+
+	if req.Avg, err = columns(req.Avg, numeric...); err != nil {
+		return err
+	}
+
+	if req.Min, err = columns(req.Min); err != nil {
+		return err
+	}
+
+	req.Max, err = columns(req.Max)
+
+	return err
+}
+
+// whereColumns checks that the keys of where are columns
+func whereColumns(sch *gormschema.Schema, where map[string]string) error {
+	for key := range where {
+		_, err := columnOf(sch, key)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
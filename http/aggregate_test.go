@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type sale struct {
+	ID        int64          `json:"id"`
+	Region    string         `json:"region"`
+	Rep       string         `json:"rep"`
+	Amount    int            `json:"amount"`
+	UnitPrice float64        `json:"unitPrice"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+}
+
+func (sale) Name() string     { return "sales" }
+func (sale) Create() any      { return &sale{} }
+func (sale) CreateArray() any { return make([]*sale, 0) }
+func (sale) Scopes() []*model.NamedFilter {
+	return []*model.NamedFilter{model.NewFilter("bigger", func(data map[string]string) model.Hook {
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where("amount > ?", data["than"])
+		}
+	})}
+}
+
+// serveSales sets up the routes of sales on an in memory sqlite db, seeded with
+// east/ann 10 at 1.5, east/bob 20 at 2.5, west/ann 5 at 1.5 and a deleted west/bob 100 at 9
+func serveSales(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one connection, every connection gets an in memory db of its own
+	pool, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetMaxOpenConns(1)
+
+	err = storage.AutoMigrate(db, sale{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	err = For(db, engine.Group(""), DefaultConfig(), sale{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{
+		`{"region":"east","rep":"ann","amount":10,"unitPrice":1.5}`,
+		`{"region":"east","rep":"bob","amount":20,"unitPrice":2.5}`,
+		`{"region":"west","rep":"ann","amount":5,"unitPrice":1.5}`,
+		`{"region":"west","rep":"bob","amount":100,"unitPrice":9}`,
+	} {
+		res := do(engine, http.MethodPost, "/sales/", body)
+
+		if res.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", res.Code)
+		}
+	}
+
+	res := do(engine, http.MethodDelete, "/sales/4", "")
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	return engine
+}
+
+func TestAggregate(t *testing.T) {
+	engine := serveSales(t)
+
+	tests := []struct {
+		name string
+		path string
+		code int
+		want string // json rows when 200
+	}{
+		{"count by default", "/sales/_aggregate", 200, `[{"count":3}]`},
+		{"group by", "/sales/_aggregate?group_by=region", 200, `[{"region":"east","count":2},{"region":"west","count":1}]`},
+		{"group by field names", "/sales/_aggregate?group_by=Region,Rep&count", 200, `[{"region":"east","rep":"ann","count":1},{"region":"east","rep":"bob","count":1},{"region":"west","rep":"ann","count":1}]`},
+		{"sum and avg", "/sales/_aggregate?group_by=region&sum[amount]&avg[UnitPrice]", 200, `[{"region":"east","sum_amount":30,"avg_unit_price":2},{"region":"west","sum_amount":5,"avg_unit_price":1.5}]`},
+		{"min and max of text", "/sales/_aggregate?min[rep]&max[rep]", 200, `[{"min_rep":"ann","max_rep":"bob"}]`},
+		{"where", "/sales/_aggregate?where[rep]=ann&sum[amount]", 200, `[{"sum_amount":15}]`},
+		{"named filter", "/sales/_aggregate?bigger[than]=5&count", 200, `[{"count":2}]`},
+		{"unknown group", "/sales/_aggregate?group_by=country", 400, ""},
+		{"unknown sum", "/sales/_aggregate?sum[total]", 400, ""},
+		{"sum of text", "/sales/_aggregate?sum[rep]", 400, ""},
+		{"avg of text", "/sales/_aggregate?avg[region]", 400, ""},
+		{"unknown where", "/sales/_aggregate?where[country]=se", 400, ""},
+		{"sql in group", "/sales/_aggregate?group_by=region)%20FROM%20sales%20--", 400, ""},
+		{"sql in max", "/sales/_aggregate?max[amount)%20FROM%20sales%20--]", 400, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(engine, http.MethodGet, test.path, "")
+
+			if res.Code != test.code {
+				t.Fatalf("expected %d, got %d", test.code, res.Code)
+			}
+
+			if test.code != 200 {
+				return
+			}
+
+			want := make([]map[string]any, 0)
+			got := make([]map[string]any, 0)
+
+			err := json.Unmarshal([]byte(test.want), &want)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = json.Unmarshal(res.Body.Bytes(), &got)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %s, got %s", test.want, res.Body.String())
+			}
+		})
+	}
+}
@@ -1,6 +1,15 @@
 package http
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+	"github.com/Meduzz/quickapi/storage"
 	"github.com/gin-gonic/gin"
 )
 
@@ -9,27 +18,60 @@ type (
 	MapExtractor  func(*gin.Context) map[string]string
 	IntExtractor  func(*gin.Context) int
 	BodyExtractor func(any, *gin.Context) (any, error)
+	AnyExtractor  func(*gin.Context) any
+	EventListener func(context.Context, *model.Event)
+
+	// ExpandExtractor reads the expanded relations as requested, they're resolved by the router
+	ExpandExtractor func(*gin.Context) []*api.Expand
+	// StringExtractor reads a string, ie the full text search
+	StringExtractor func(*gin.Context) string
+	// ListExtractor reads a comma separated (or repeated) list
+	ListExtractor func(*gin.Context) []string
+	// AggregateExtractor reads the group by columns and aggregate functions, where and hooks are added by the router
+	AggregateExtractor func(*gin.Context) *api.Aggregate
 
 	Configurer func(*Config)
 
 	Config struct {
 		ID      IDExtractor
 		Preload MapExtractor
+		Expand  ExpandExtractor
+		Query   StringExtractor
 		Sorting MapExtractor
 		Where   MapExtractor
 		Skip    IntExtractor
 		Take    IntExtractor
 		Body    BodyExtractor
+
+		Aggregate AggregateExtractor
+		Facets    ListExtractor
+
+		Storage   storage.Factory
+		Principal AnyExtractor
+		Listeners []EventListener
+		Timeouts  map[string]time.Duration // query timeouts by entity and/or operation
+		Validate  bool                     // validate patches against the json schema of the entity
+
+		ExpandDepth int // max relations in an expanded path, 0 disables expand
+
+		Params *openapi.Params // names of the parameters read by the strategies, for the OpenAPI document
 	}
 )
 
 const (
 	ID      = "id"
 	PRELOAD = "preload"
+	EXPAND  = "expand"
+	QUERY   = "q"
 	TAKE    = "take"
 	SKIP    = "skip"
 	WHERE   = "where"
 	SORT    = "sort"
+
+	GROUP_BY = "group_by"
+	FIELD    = "field"
+
+	PRINCIPAL = "principal"
 )
 
 func DefaultConfig() *Config {
@@ -37,11 +79,18 @@ func DefaultConfig() *Config {
 
 	WithPathParamIdStrategy(ID)(cfg)
 	WithPreloadQueryMapStrategy(PRELOAD)(cfg)
+	WithExpandQueryStrategy(EXPAND)(cfg)
+	WithExpandDepth(2)(cfg)
+	WithAggregateQueryStrategy(GROUP_BY)(cfg)
+	WithFullTextQueryStrategy(QUERY)(cfg)
+	WithFacetQueryStrategy(FIELD)(cfg)
 	WithSortingQueryMapStrategy(SORT)(cfg)
 	WithWhereQueryMapStrategy(WHERE)(cfg)
 	WithSkipQueryIntStrategy(SKIP, 0)(cfg)
 	WithTakeQueryIntStrategy(TAKE, 25)(cfg)
 	WithJsonBodyExtractor()(cfg)
+	WithPrincipalKeyStrategy(PRINCIPAL)(cfg)
+	WithStorage(storage.GormFactory)(cfg)
 
 	return cfg
 }
@@ -49,6 +98,7 @@ func DefaultConfig() *Config {
 func WithPathParamIdStrategy(param string) Configurer {
 	return func(c *Config) {
 		c.ID = ExtractID(param)
+		c.params().ID = param
 	}
 }
 
@@ -57,36 +107,103 @@ func WithQueryparamIdStrategy(param string) Configurer {
 		c.ID = func(ctx *gin.Context) string {
 			return ctx.Query(param)
 		}
+		c.params().ID = param
 	}
 }
 
 func WithPreloadQueryMapStrategy(param string) Configurer {
 	return func(c *Config) {
 		c.Preload = ExtractQueryMap(param)
+		c.params().Preload = param
+	}
+}
+
+// WithExpandQueryStrategy reads expanded relations from param, ie expand=pets,pets.toys
+// with optional conditions per relation, ie expand.pets.where[name]=rex, expand.pets.sort[name]=desc & expand.pets.take=5
+func WithExpandQueryStrategy(param string) Configurer {
+	return func(c *Config) {
+		c.Expand = ExtractExpand(param)
+		c.params().Expand = param
+	}
+}
+
+// WithExpandDepth limits the number of relations in an expanded path, ie pets.toys is 2, 0 disables expand.
+func WithExpandDepth(depth int) Configurer {
+	return func(c *Config) {
+		c.ExpandDepth = depth
+	}
+}
+
+// WithFullTextQueryStrategy reads the full text search of searchable entities from param, ie q=jon
+func WithFullTextQueryStrategy(param string) Configurer {
+	return func(c *Config) {
+		c.Query = func(ctx *gin.Context) string {
+			return strings.TrimSpace(ctx.Query(param))
+		}
+		c.params().Query = param
+	}
+}
+
+// WithAggregateQueryStrategy reads the group by columns from param, ie group_by=owner,kind,
+// and the aggregates from count, sum[column], avg[column], min[column] & max[column]
+func WithAggregateQueryStrategy(param string) Configurer {
+	return func(c *Config) {
+		c.Aggregate = ExtractAggregate(param)
+		c.params().GroupBy = param
+	}
+}
+
+// WithFacetQueryStrategy reads the fields to count distinct values of from param, ie field=status&field=age
+func WithFacetQueryStrategy(param string) Configurer {
+	return func(c *Config) {
+		c.Facets = ExtractList(param)
+		c.params().Field = param
 	}
 }
 
 func WithSortingQueryMapStrategy(param string) Configurer {
 	return func(c *Config) {
 		c.Sorting = ExtractQueryMap(param)
+		c.params().Sort = param
 	}
 }
 
 func WithWhereQueryMapStrategy(param string) Configurer {
 	return func(c *Config) {
 		c.Where = ExtractQueryMap(param)
+		c.params().Where = param
 	}
 }
 
 func WithSkipQueryIntStrategy(param string, defaultValue int) Configurer {
 	return func(c *Config) {
 		c.Skip = ExtractQueryInt(param, defaultValue)
+		c.params().Skip = param
 	}
 }
 
 func WithTakeQueryIntStrategy(param string, defaultValue int) Configurer {
 	return func(c *Config) {
 		c.Take = ExtractQueryInt(param, defaultValue)
+		c.params().Take = param
+	}
+}
+
+// WithMaxTake limits take to max, unlimited (negative) takes are limited as well.
+// Apply it after the take strategy.
+func WithMaxTake(max int) Configurer {
+	return func(c *Config) {
+		take := c.Take
+
+		c.Take = func(ctx *gin.Context) int {
+			it := take(ctx)
+
+			if it < 0 || it > max {
+				return max
+			}
+
+			return it
+		}
 	}
 }
 
@@ -95,3 +212,121 @@ func WithJsonBodyExtractor() Configurer {
 		c.Body = ExtractBody
 	}
 }
+
+// WithSchemaValidation validates create, update and patch bodies against
+// the json schema of the entity (see /_meta?format=jsonschema) before binding them.
+func WithSchemaValidation() Configurer {
+	return func(c *Config) {
+		c.Body = ExtractValidatedBody
+		c.Validate = true
+	}
+}
+
+// WithStorage sets the factory used to create the storage of each entity.
+func WithStorage(factory storage.Factory) Configurer {
+	return func(c *Config) {
+		c.Storage = factory
+	}
+}
+
+// WithPrincipalKeyStrategy reads the principal handed to lifecycle hooks
+// from the gin context key, set by an authentication middleware.
+func WithPrincipalKeyStrategy(key string) Configurer {
+	return func(c *Config) {
+		c.Principal = ExtractKey(key)
+	}
+}
+
+// WithEventListener adds a listener that is called after each successful create, update, patch and delete.
+func WithEventListener(listener EventListener) Configurer {
+	return func(c *Config) {
+		c.Listeners = append(c.Listeners, listener)
+	}
+}
+
+// WithTimeout sets the query timeout of all operations on all entities.
+func WithTimeout(timeout time.Duration) Configurer {
+	return WithOperationTimeout("", "", timeout)
+}
+
+// WithEntityTimeout sets the query timeout of all operations on the named entity.
+func WithEntityTimeout(entity string, timeout time.Duration) Configurer {
+	return WithOperationTimeout(entity, "", timeout)
+}
+
+// WithOperationTimeout sets the query timeout of an operation on the named entity,
+// leave entity empty to set it for all entities.
+func WithOperationTimeout(entity string, operation model.Operation, timeout time.Duration) Configurer {
+	return func(c *Config) {
+		if c.Timeouts == nil {
+			c.Timeouts = make(map[string]time.Duration)
+		}
+
+		c.Timeouts[timeoutKey(entity, operation)] = timeout
+	}
+}
+
+// withDefaults is a copy of the config, with the funcs it leaves out (ie a config built by hand) from DefaultConfig
+func (c *Config) withDefaults() *Config {
+	defaults := DefaultConfig()
+	it := *c
+
+	if it.Storage == nil {
+		it.Storage = defaults.Storage
+	}
+
+	if it.Principal == nil {
+		it.Principal = defaults.Principal
+	}
+
+	if it.Expand == nil {
+		it.Expand = defaults.Expand
+	}
+
+	if it.Aggregate == nil {
+		it.Aggregate = defaults.Aggregate
+	}
+
+	if it.Query == nil {
+		it.Query = defaults.Query
+	}
+
+	if it.Facets == nil {
+		it.Facets = defaults.Facets
+	}
+
+	return &it
+}
+
+// params are the names of the parameters, created on first use
+func (c *Config) params() *openapi.Params {
+	if c.Params == nil {
+		c.Params = &openapi.Params{}
+	}
+
+	return c.Params
+}
+
+// Timeout finds the most specific timeout of operation on entity, 0 means no timeout.
+func (c *Config) Timeout(entity string, operation model.Operation) time.Duration {
+	keys := []string{
+		timeoutKey(entity, operation),
+		timeoutKey(entity, ""),
+		timeoutKey("", operation),
+		timeoutKey("", ""),
+	}
+
+	for _, key := range keys {
+		timeout, ok := c.Timeouts[key]
+
+		if ok {
+			return timeout
+		}
+	}
+
+	return 0
+}
+
+func timeoutKey(entity string, operation model.Operation) string {
+	return fmt.Sprintf("%s/%s", entity, operation)
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type (
+	// CORS configures the CORS middleware, an origin of * allows all origins
+	CORS struct {
+		Origins     []string `json:"origins" yaml:"origins" toml:"origins"`
+		Methods     []string `json:"methods" yaml:"methods" toml:"methods"`
+		Headers     []string `json:"headers" yaml:"headers" toml:"headers"`
+		Credentials bool     `json:"credentials" yaml:"credentials" toml:"credentials"`
+		MaxAge      int      `json:"max_age" yaml:"max_age" toml:"max_age"` // seconds
+	}
+)
+
+// Cors creates a middleware that answers preflight requests and adds CORS headers for allowed origins.
+func Cors(cors *CORS) gin.HandlerFunc {
+	methods := cors.Methods
+
+	if len(methods) == 0 {
+		methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	}
+
+	headers := cors.Headers
+
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "Authorization"}
+	}
+
+	all := slices.Contains(cors.Origins, "*")
+
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader("Origin")
+
+		if origin == "" || (!all && !slices.Contains(cors.Origins, origin)) {
+			ctx.Next()
+			return
+		}
+
+		if all && !cors.Credentials {
+			ctx.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			ctx.Header("Access-Control-Allow-Origin", origin)
+			ctx.Header("Vary", "Origin")
+		}
+
+		if cors.Credentials {
+			ctx.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if ctx.Request.Method != http.MethodOptions || ctx.GetHeader("Access-Control-Request-Method") == "" {
+			ctx.Next()
+			return
+		}
+
+		// preflight
+		ctx.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		ctx.Header("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+
+		if cors.MaxAge > 0 {
+			ctx.Header("Access-Control-Max-Age", fmt.Sprint(cors.MaxAge))
+		}
+
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
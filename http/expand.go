@@ -0,0 +1,173 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/gin-gonic/gin"
+	gormschema "gorm.io/gorm/schema"
+)
+
+// expansions resolves the expanded relations of the request against the gorm schema of the entity,
+// unknown, too deep or not allowed relations and unknown columns are errors.
+// Take is only per owner when one owner is read, so it's an error on search and on nested relations.
+func (r *router) expansions(ctx *gin.Context, operation model.Operation) ([]*api.Expand, error) {
+	expand := r.config.Expand(ctx)
+
+	if len(expand) == 0 {
+		return nil, nil
+	}
+
+	if r.config.ExpandDepth < 1 {
+		return nil, fmt.Errorf("expand is disabled")
+	}
+
+	if model.KindOf(r.entity) != model.KindNormal {
+		return nil, fmt.Errorf("%s has no relations to expand", r.entity.Name())
+	}
+
+	expandSupport, ok := r.entity.(model.ExpandSupport)
+
+	if !ok {
+		return nil, fmt.Errorf("%s does not allow expand", r.entity.Name())
+	}
+
+	sch, err := model.Schema(r.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := allowedExpands(expandSupport, sch)
+	result := make([]*api.Expand, 0, len(expand))
+
+	for _, it := range expand {
+		if strings.Count(it.Relation, ".")+1 > r.config.ExpandDepth {
+			return nil, fmt.Errorf("expand %s is deeper than %d", it.Relation, r.config.ExpandDepth)
+		}
+
+		relation, related, err := resolveRelation(sch, it.Relation)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !allowed[relation] {
+			return nil, fmt.Errorf("expand %s is not allowed", it.Relation)
+		}
+
+		// gorm limits the preloaded rows in total, not per owner
+		if it.Take > 0 && (operation != model.OperationRead || strings.Contains(relation, ".")) {
+			return nil, fmt.Errorf("take of expand %s is only supported when reading its owner", it.Relation)
+		}
+
+		resolved := &api.Expand{
+			Relation: relation,
+			Where:    make(map[string]string),
+			Sort:     make(map[string]string),
+			Take:     max(it.Take, 0),
+		}
+
+		for key, value := range it.Where {
+			column, err := columnOf(related, key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			resolved.Where[column] = value
+		}
+
+		for key, value := range it.Sort {
+			column, err := columnOf(related, key)
+
+			if err != nil {
+				return nil, err
+			}
+
+			direction := strings.ToLower(value)
+
+			if direction != "asc" && direction != "desc" {
+				return nil, fmt.Errorf("sort %s by %s, not asc or desc", key, value)
+			}
+
+			resolved.Sort[column] = direction
+		}
+
+		result = append(result, resolved)
+	}
+
+	return result, nil
+}
+
+// allowedExpands resolves the allow-list of the entity.
+// The relations on the way to an allowed relation are preloaded by gorm anyway, so they're allowed too.
+func allowedExpands(expandSupport model.ExpandSupport, sch *gormschema.Schema) map[string]bool {
+	allowed := make(map[string]bool)
+
+	for _, path := range expandSupport.Expands() {
+		relation, _, err := resolveRelation(sch, path)
+
+		if err != nil {
+			println("resolving allowed expand threw error", err.Error())
+			continue
+		}
+
+		names := strings.Split(relation, ".")
+
+		for i := range names {
+			allowed[strings.Join(names[:i+1], ".")] = true
+		}
+	}
+
+	return allowed
+}
+
+// resolveRelation turns a path of field or json names into a path of field names, and the schema it ends in
+func resolveRelation(sch *gormschema.Schema, path string) (string, *gormschema.Schema, error) {
+	names := make([]string, 0)
+
+	for _, name := range strings.Split(path, ".") {
+		relation := relationOf(sch, name)
+
+		if relation == nil {
+			return "", nil, fmt.Errorf("unknown relation %s in %s", name, path)
+		}
+
+		names = append(names, relation.Name)
+		sch = relation.FieldSchema
+	}
+
+	return strings.Join(names, "."), sch, nil
+}
+
+func relationOf(sch *gormschema.Schema, name string) *gormschema.Relationship {
+	relation, ok := sch.Relationships.Relations[name]
+
+	if ok {
+		return relation
+	}
+
+	for _, relation := range sch.Relationships.Relations {
+		field, _ := jsonName(relation.Field.StructField)
+
+		if field == name {
+			return relation
+		}
+	}
+
+	return nil
+}
+
+// columnOf finds the column of a field or column name
+func columnOf(sch *gormschema.Schema, name string) (string, error) {
+	field := sch.LookUpField(name)
+
+	if field == nil || field.DBName == "" {
+		return "", fmt.Errorf("unknown column %s in %s", name, sch.Table)
+	}
+
+	return field.DBName, nil
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type (
+	owner struct {
+		ID    int64    `json:"id"`
+		Title string   `json:"title"`
+		Pets  []*owned `gorm:"foreignKey:OwnerID" json:"pets,omitempty"`
+	}
+
+	// unlisted has the relations of owner, but no allow-list
+	unlisted struct {
+		ID    int64    `json:"id"`
+		Title string   `json:"title"`
+		Pets  []*owned `gorm:"foreignKey:OwnerID" json:"pets,omitempty"`
+	}
+
+	owned struct {
+		ID      int64  `json:"id"`
+		Title   string `json:"title"`
+		OwnerID int64  `json:"-"`
+		Toys    []*toy `gorm:"foreignKey:OwnedID" json:"toys,omitempty"`
+	}
+
+	toy struct {
+		ID      int64  `json:"id"`
+		Title   string `json:"title"`
+		OwnedID int64  `json:"-"`
+	}
+)
+
+func (owner) Name() string        { return "owners" }
+func (owner) Create() any         { return &owner{} }
+func (owner) CreateArray() any    { return make([]*owner, 0) }
+func (owner) Expands() []string   { return []string{"pets.toys"} }
+func (unlisted) Name() string     { return "unlisted" }
+func (unlisted) Create() any      { return &unlisted{} }
+func (unlisted) CreateArray() any { return make([]*unlisted, 0) }
+
+// serveGorm sets up the routes of the entities on an in memory sqlite db, seeded with an owner of 3 pets with 2 toys each
+func serveGorm(t *testing.T, entities ...model.Entity) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one connection, every connection gets an in memory db of its own
+	pool, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetMaxOpenConns(1)
+
+	err = db.AutoMigrate(&owned{}, &toy{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entity := range entities {
+		err = storage.AutoMigrate(db, entity)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pets := make([]*owned, 0)
+
+	for _, title := range []string{"a", "b", "c"} {
+		pets = append(pets, &owned{Title: title, OwnerID: 1, Toys: []*toy{{Title: title + "1"}, {Title: title + "2"}}})
+	}
+
+	err = db.Create(pets).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	err = For(db, engine.Group(""), DefaultConfig(), entities...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entity := range entities {
+		res := do(engine, http.MethodPost, "/"+entity.Name()+"/", `{"title":"o"}`)
+
+		if res.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", res.Code)
+		}
+	}
+
+	return engine
+}
+
+func TestExtractExpand(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/?expand=pets,pets.toys&expand=tags&expand.pets.where[title]=a&expand.pets.sort[title]=desc&expand.pets.take=5", nil)
+
+	expand := ExtractExpand(EXPAND)(ctx)
+
+	if len(expand) != 3 {
+		t.Fatalf("expected 3 expansions, got %d", len(expand))
+	}
+
+	pets, toys, tags := expand[0], expand[1], expand[2]
+
+	if pets.Relation != "pets" || pets.Where["title"] != "a" || pets.Sort["title"] != "desc" || pets.Take != 5 {
+		t.Errorf("expected the conditions of pets, got %+v", pets)
+	}
+
+	if toys.Relation != "pets.toys" || len(toys.Where) != 0 || len(toys.Sort) != 0 || toys.Take != 0 {
+		t.Errorf("expected pets.toys without conditions, got %+v", toys)
+	}
+
+	if tags.Relation != "tags" {
+		t.Errorf("expected tags, got %+v", tags)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	engine := serveGorm(t, owner{}, unlisted{})
+
+	tests := []struct {
+		name string
+		path string
+		code int
+		pets int
+		toys int
+	}{
+		{"read", "/owners/1?expand=pets.toys", 200, 3, 2},
+		{"filtered", "/owners/1?expand=pets&expand.pets.where[title]=b", 200, 1, 0},
+		{"take per owner on read", "/owners/1?expand=pets&expand.pets.take=2", 200, 2, 0},
+		{"take of nested relation", "/owners/1?expand=pets.toys&expand.pets.toys.take=1", 400, 0, 0},
+		{"search", "/owners/?expand=pets", 200, 3, 0},
+		{"take on search", "/owners/?expand=pets&expand.pets.take=2", 400, 0, 0},
+		{"unknown relation", "/owners/1?expand=friends", 400, 0, 0},
+		{"unknown column", "/owners/1?expand=pets&expand.pets.where[age]=1", 400, 0, 0},
+		{"too deep", "/owners/1?expand=pets.toys.parts", 400, 0, 0},
+		{"without allow-list", "/unlisted/1?expand=pets", 400, 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(engine, http.MethodGet, test.path, "")
+
+			if res.Code != test.code {
+				t.Fatalf("expected %d, got %d", test.code, res.Code)
+			}
+
+			if res.Code != http.StatusOK {
+				return
+			}
+
+			read := &owner{}
+			found := make([]*owner, 0)
+			err := json.Unmarshal(res.Body.Bytes(), &found)
+
+			if err != nil {
+				err = json.Unmarshal(res.Body.Bytes(), read)
+			} else {
+				read = found[0]
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(read.Pets) != test.pets {
+				t.Fatalf("expected %d pets, got %d", test.pets, len(read.Pets))
+			}
+
+			for _, pet := range read.Pets {
+				if len(pet.Toys) != test.toys {
+					t.Fatalf("expected %d toys, got %d", test.toys, len(pet.Toys))
+				}
+			}
+		})
+	}
+}
+
+func TestOpenApiOnlyDocumentsAllowedExpand(t *testing.T) {
+	engine := serve(t, nil, owner{}, unlisted{})
+	res := do(engine, http.MethodGet, "/_openapi.json", "")
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	doc := make(map[string]any)
+	err := json.Unmarshal(res.Body.Bytes(), &doc)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := func(path string) bool {
+		operation := doc["paths"].(map[string]any)[path].(map[string]any)["get"].(map[string]any)
+
+		for _, parameter := range operation["parameters"].([]any) {
+			if parameter.(map[string]any)["name"] == EXPAND {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if !documented("/owners/") {
+		t.Error("expected expand on owners")
+	}
+
+	if documented("/unlisted/") {
+		t.Error("expected no expand on unlisted")
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Facets responds with the most common values of each field, and their counts,
+// of the rows matching where, the named filters and the full text search
+func (r *router) Facets(ctx *gin.Context) {
+	take := r.config.Take(ctx)
+	where := r.config.Where(ctx)
+	query, err := r.fullText(ctx)
+
+	if err != nil {
+		println("reading full text search threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	fields, err := r.facetColumns(r.config.Facets(ctx), where)
+
+	if err != nil {
+		println("resolving facets threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := r.hookContext(ctx, model.OperationSearch)
+	defer cancel()
+
+	err = r.beforeSearch(hctx, where)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
+	req := api.NewFacets(fields, where, take, CreateHooks(r.entity, ctx))
+	req.Query = query
+
+	facets, err := r.storage.Facets(hctx, req)
+
+	if err != nil {
+		println("counting facets threw error", err.Error())
+
+		if strings.Contains(err.Error(), "syntax error") || strings.Contains(err.Error(), "no such column") {
+			ctx.AbortWithStatus(400)
+			return
+		}
+
+		code := codeFromError(err)
+
+		ctx.AbortWithStatus(code)
+		return
+	}
+
+	ctx.JSON(200, facets)
+}
+
+// facetColumns turns field names into columns, facets are keyed by column. Json entities are left to the storage.
+func (r *router) facetColumns(fields []string, where map[string]string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields")
+	}
+
+	if model.KindOf(r.entity) != model.KindNormal {
+		return fields, nil
+	}
+
+	sch, err := model.Schema(r.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = whereColumns(sch, where)
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		column, err := columnOf(sch, field)
+
+		if err != nil {
+			return nil, err
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
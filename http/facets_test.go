@@ -0,0 +1,63 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFacets(t *testing.T) {
+	engine := serveSales(t)
+
+	tests := []struct {
+		name string
+		path string
+		code int
+		want string // json facets when 200
+	}{
+		{"one field", "/sales/_facets?field=rep", 200, `{"rep":[{"value":"ann","count":2},{"value":"bob","count":1}]}`},
+		{"repeated and listed fields", "/sales/_facets?field=rep,region&field=Amount", 200, `{"rep":[{"value":"ann","count":2},{"value":"bob","count":1}],"region":[{"value":"east","count":2},{"value":"west","count":1}],"amount":[{"value":5,"count":1},{"value":10,"count":1},{"value":20,"count":1}]}`},
+		{"keyed by column", "/sales/_facets?field=UnitPrice", 200, `{"unit_price":[{"value":1.5,"count":2},{"value":2.5,"count":1}]}`},
+		{"take", "/sales/_facets?field=rep&take=1", 200, `{"rep":[{"value":"ann","count":2}]}`},
+		{"where", "/sales/_facets?field=rep&where[region]=west", 200, `{"rep":[{"value":"ann","count":1}]}`},
+		{"named filter", "/sales/_facets?field=region&bigger[than]=5", 200, `{"region":[{"value":"east","count":2}]}`},
+		{"no field", "/sales/_facets", 400, ""},
+		{"unknown field", "/sales/_facets?field=country", 400, ""},
+		{"unknown where", "/sales/_facets?field=rep&where[country]=se", 400, ""},
+		{"sql in field", "/sales/_facets?field=rep%22%20FROM%20sales%20--", 400, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(engine, http.MethodGet, test.path, "")
+
+			if res.Code != test.code {
+				t.Fatalf("expected %d, got %d", test.code, res.Code)
+			}
+
+			if test.code != 200 {
+				return
+			}
+
+			want := make(map[string]any)
+			got := make(map[string]any)
+
+			err := json.Unmarshal([]byte(test.want), &want)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = json.Unmarshal(res.Body.Bytes(), &got)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("expected %s, got %s", test.want, res.Body.String())
+			}
+		})
+	}
+}
@@ -1,11 +1,19 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/quickapi/api"
 	"github.com/Meduzz/quickapi/model"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 func ExtractID(param string) func(*gin.Context) string {
@@ -20,11 +28,91 @@ func ExtractQueryMap(param string) func(*gin.Context) map[string]string {
 	}
 }
 
+func ExtractKey(key string) func(*gin.Context) any {
+	return func(ctx *gin.Context) any {
+		it, _ := ctx.Get(key)
+		return it
+	}
+}
+
+// ExtractExpand reads a comma separated (or repeated) list of relation paths from param,
+// the conditions of each path are read from param.path.where, param.path.sort and param.path.take
+func ExtractExpand(param string) func(*gin.Context) []*api.Expand {
+	return func(ctx *gin.Context) []*api.Expand {
+		return slice.Map(ExtractList(param)(ctx), func(path string) *api.Expand {
+			prefix := fmt.Sprintf("%s.%s", param, path)
+
+			return &api.Expand{
+				Relation: path,
+				Where:    ctx.QueryMap(prefix + ".where"),
+				Sort:     ctx.QueryMap(prefix + ".sort"),
+				Take:     ExtractQueryInt(prefix+".take", 0)(ctx),
+			}
+		})
+	}
+}
+
+// ExtractAggregate reads a comma separated (or repeated) list of group by columns from param,
+// count when it's present and the columns of sum, avg, min & max from their keys, ie sum[age]
+func ExtractAggregate(param string) func(*gin.Context) *api.Aggregate {
+	return func(ctx *gin.Context) *api.Aggregate {
+		aggregate := api.NewAggregate(nil, ExtractList(param)(ctx), nil)
+		_, aggregate.Count = ctx.GetQuery("count")
+		aggregate.Sum = slices.Sorted(maps.Keys(ctx.QueryMap("sum")))
+		aggregate.Avg = slices.Sorted(maps.Keys(ctx.QueryMap("avg")))
+		aggregate.Min = slices.Sorted(maps.Keys(ctx.QueryMap("min")))
+		aggregate.Max = slices.Sorted(maps.Keys(ctx.QueryMap("max")))
+
+		return aggregate
+	}
+}
+
+// ExtractList reads a comma separated (or repeated) query param
+func ExtractList(param string) func(*gin.Context) []string {
+	return func(ctx *gin.Context) []string {
+		items := strings.Split(strings.Join(ctx.QueryArray(param), ","), ",")
+		items = slice.Map(items, strings.TrimSpace)
+
+		return slice.Filter(items, func(item string) bool {
+			return item != ""
+		})
+	}
+}
+
 func ExtractBody(entity any, ctx *gin.Context) (any, error) {
 	err := ctx.BindJSON(entity)
 	return entity, err
 }
 
+// ExtractValidatedBody validates the json body against the json schema of entity before binding it.
+func ExtractValidatedBody(entity any, ctx *gin.Context) (any, error) {
+	bs, err := ctx.GetRawData()
+
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := decodeDocument(bs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = schemaOf(reflect.TypeOf(entity)).Validate(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(bs, entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entity, binding.Validator.ValidateStruct(entity)
+}
+
 func CreateHooks(entity model.Entity, ctx *gin.Context) []model.Hook {
 	hooks := make([]model.Hook, 0)
 	scopeSupport, ok := entity.(model.ScopeSupport)
@@ -3,20 +3,42 @@ package http
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/Meduzz/helper/fp/slice"
 	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+	"github.com/Meduzz/quickapi/schema"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	gormschema "gorm.io/gorm/schema"
 )
 
+var timeType = reflect.TypeOf(time.Time{})
+
 type (
+	// Spec describes an entity and what the api can do with it
 	Spec struct {
-		Name   string  `json:"name,omitempty"`   // entity.Name
-		Kind   string  `json:"kind,omitempty"`   // quickapi entity.Kind (normal|json)
-		Type   string  `json:"type,omitempty"`   // struct.name
-		Entity *Entity `json:"entity,omitempty"` // struct.fields
+		Name       string            `json:"name,omitempty"`       // entity.Name
+		Kind       string            `json:"kind,omitempty"`       // quickapi entity.Kind (normal|json)
+		Type       string            `json:"type,omitempty"`       // struct.name
+		PrimaryKey string            `json:"primaryKey,omitempty"` // primary key column, comma separated for composite keys
+		IDStrategy string            `json:"idStrategy,omitempty"` // how ids are created, see model.IDStrategy
+		Operations []model.Operation `json:"operations"`           // enabled operations
+		Filters    []string          `json:"filters,omitempty"`    // named filters from ScopeSupport
+		Preloads   []string          `json:"preloads,omitempty"`   // preload aliases from PreloadDiscovery
+		Searchable []string          `json:"searchable,omitempty"` // full text searchable columns
+		Relations  []*Relation       `json:"relations,omitempty"`  // relations known to gorm
+		Entity     *Entity           `json:"entity,omitempty"`     // struct.fields
+	}
+
+	Relation struct {
+		Name  string `json:"name"`  // struct field, as used in preloads
+		Field string `json:"field"` // json name of the field
+		Type  string `json:"type"`  // has_one | has_many | belongs_to | many_to_many
+		Table string `json:"table"` // table of the related struct
 	}
 
 	Entity struct {
@@ -34,6 +56,7 @@ type (
 
 	Discovery struct {
 		Entities []string `json:"entities"`
+		Specs    []*Spec  `json:"specs"`
 	}
 )
 
@@ -41,32 +64,89 @@ type (
 // but leaves up to you to deal with the server and run migrations.
 func For(db *gorm.DB, e *gin.RouterGroup, config *Config, entities ...model.Entity) error {
 	discovery := &Discovery{}
+	config = config.withDefaults()
+	nested := make(map[string]bool)
+
+	for _, entity := range entities {
+		r, err := newRouter(db, config, entity)
+
+		if err != nil {
+			return err
+		}
 
-	listOfNames := slice.Map(entities, func(entity model.Entity) string {
-		r := newRouter(db, config, entity)
 		api := e.Group(fmt.Sprintf("/%s", entity.Name()))
+		spec := SpecFor(entity)
+
+		// setup REST endpoints, for the enabled operations
+		routes := map[model.Operation]func(){
+			model.OperationCreate: func() { api.POST("/", r.Create) },
+			model.OperationRead:   func() { api.GET("/:id", r.Read) },
+			model.OperationUpdate: func() { api.PUT("/:id", r.Update) },
+			model.OperationDelete: func() { api.DELETE("/:id", r.Delete) },
+			model.OperationSearch: func() { api.GET("/", r.Search) },
+			model.OperationPatch:  func() { api.PATCH("/:id", r.Patch) },
+		}
 
-		// setup REST endpoints
-		api.POST("/", r.Create)                          // create
-		api.GET("/:id", r.Read)                          // read
-		api.PUT("/:id", r.Update)                        // update
-		api.DELETE("/:id", r.Delete)                     // delete
-		api.GET("/", r.Search)                           // list/search
-		api.PATCH("/:id", r.Patch)                       // patch
-		api.GET("/_meta", serveMeta(entityMeta(entity))) // TODO make this opt-in too?
+		slice.ForEach(spec.Operations, func(operation model.Operation) {
+			route, ok := routes[operation]
 
-		return entity.Name()
-	})
+			if ok {
+				route()
+			}
+		})
+
+		if model.Enabled(entity, model.OperationSearch) {
+			api.GET("/_aggregate", r.Aggregate)
+			api.GET("/_facets", r.Facets)
+		}
 
-	discovery.Entities = listOfNames
+		nested[entity.Name()], err = nestedRoutes(r, api, spec)
+
+		if err != nil {
+			return err
+		}
+
+		api.GET("/_meta", serveMeta(entity, spec)) // TODO make this opt-in too?
+
+		discovery.Entities = append(discovery.Entities, entity.Name())
+		discovery.Specs = append(discovery.Specs, spec)
+	}
 
 	e.GET("/_discover", func(ctx *gin.Context) {
 		ctx.JSON(200, discovery)
 	})
 
+	params := config.Params
+
+	if params == nil {
+		params = DefaultParams()
+	}
+
+	// documented with the parameter names of config, and only the nested routes that were registered
+	doc := openapi.Build(openapi.DefaultInfo(), e.BasePath(), params, func(entity model.Entity) bool {
+		return nested[entity.Name()]
+	}, entities...)
+
+	e.GET("/_openapi.json", func(ctx *gin.Context) {
+		ctx.JSON(200, doc)
+	})
+
 	return nil
 }
 
+// OpenApi creates the OpenAPI document of the entities, as served by For under basePath with the default config.
+// The default config stores entities with gorm, so their nested routes are documented.
+func OpenApi(info *openapi.Info, basePath string, entities ...model.Entity) *openapi.Document {
+	return openapi.Build(info, basePath, DefaultParams(), func(model.Entity) bool {
+		return true
+	}, entities...)
+}
+
+// DefaultParams are the names of the parameters read with the default config, see openapi.DefaultParams.
+func DefaultParams() *openapi.Params {
+	return openapi.DefaultParams()
+}
+
 func createScopes(ctx *gin.Context, filters []*model.NamedFilter) []model.Hook {
 	if len(filters) == 0 {
 		return nil
@@ -85,73 +165,179 @@ func createScopes(ctx *gin.Context, filters []*model.NamedFilter) []model.Hook {
 	return scopes
 }
 
-func serveMeta(entity any) func(*gin.Context) {
+// serveMeta serves the entity spec, or its json schema with ?format=jsonschema
+func serveMeta(entity model.Entity, spec *Spec) func(*gin.Context) {
+	document := schema.Document(entity)
+
 	return func(ctx *gin.Context) {
-		ctx.JSON(200, entity)
+		if ctx.Query("format") == "jsonschema" {
+			ctx.JSON(200, document)
+			return
+		}
+
+		ctx.JSON(200, spec)
+	}
+}
+
+// SpecFor describes the entity, as served by /_meta and /_discover
+func SpecFor(entity model.Entity) *Spec {
+	spec := &Spec{
+		Name:       entity.Name(),
+		Kind:       string(model.KindOf(entity)),
+		Operations: model.OperationsOf(entity),
+		Entity:     entityMeta(entity),
+	}
+
+	if spec.Entity != nil {
+		spec.Type = spec.Entity.Name
+	}
+
+	if scopeSupport, ok := entity.(model.ScopeSupport); ok {
+		spec.Filters = slice.Map(scopeSupport.Scopes(), func(filter *model.NamedFilter) string {
+			return filter.Name
+		})
+	}
+
+	if discovery, ok := entity.(model.PreloadDiscovery); ok {
+		spec.Preloads = discovery.Preloads()
+	}
+
+	if key, err := model.KeyOf(entity); err == nil {
+		spec.PrimaryKey = strings.Join(key.Columns(), ",")
+		spec.IDStrategy = string(key.Strategy)
+	}
+
+	if model.KindOf(entity) == model.KindJson {
+		// stored as a storage.JsonRow
+		return spec
+	}
+
+	sch, err := model.Schema(entity)
+
+	if err != nil {
+		println("parsing schema threw error", err.Error())
+		return spec
+	}
+
+	spec.Searchable, err = model.SearchableOf(entity)
+
+	if err != nil {
+		println("finding searchable columns threw error", err.Error())
 	}
+
+	for _, relation := range sch.Relationships.Relations {
+		name, _ := jsonName(relation.Field.StructField)
+
+		spec.Relations = append(spec.Relations, &Relation{
+			Name:  relation.Name,
+			Field: name,
+			Type:  relationType(relation.Type),
+			Table: relation.FieldSchema.Table,
+		})
+	}
+
+	sort.Slice(spec.Relations, func(i, j int) bool {
+		return spec.Relations[i].Name < spec.Relations[j].Name
+	})
+
+	return spec
 }
 
-func entityMeta(entity model.Entity) any {
-	data := entity.Create()
-	var def any
+func relationType(kind gormschema.RelationshipType) string {
+	if kind == gormschema.Many2Many {
+		return "many_to_many"
+	}
+
+	return string(kind)
+}
 
-	v := reflect.ValueOf(data)
+func entityMeta(entity model.Entity) *Entity {
+	t := reflect.TypeOf(entity.Create())
 
-	if v.Kind() == reflect.Pointer {
-		v = v.Elem() // drop pointer
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem() // drop pointer
 	}
 
-	if v.Kind() == reflect.Struct {
-		def = parseStruct(v)
-	} else {
-		t := v.Type()
-		def = t.String()
+	if t.Kind() != reflect.Struct {
+		return nil
 	}
 
-	return def
+	return parseStruct(t, make(map[reflect.Type]bool))
 }
 
-func parseStruct(v reflect.Value) *Entity {
+// parseStruct describes the fields of t, seen stops recursive types
+func parseStruct(t reflect.Type, seen map[reflect.Type]bool) *Entity {
 	p := &Entity{}
-	p.Name = v.Type().Name()
+	p.Name = t.Name()
+	p.Fields = make([]*Field, 0)
 
-	fieldCount := v.NumField()
+	if seen[t] {
+		// recursive type, the fields are described further up
+		return p
+	}
 
-	for i := 0; i < fieldCount; i++ {
-		rf := v.Type().Field(i)
+	seen[t] = true
+	defer delete(seen, t)
 
-		p.Fields = append(p.Fields, parseField(rf))
-	}
+	parseFields(t, seen, p)
 
 	return p
 }
 
-func parseField(rf reflect.StructField) *Field {
-	f := &Field{}
+func parseFields(t reflect.Type, seen map[reflect.Type]bool, p *Entity) {
+	for i := 0; i < t.NumField(); i++ {
+		rf := t.Field(i)
+		name, skip := jsonName(rf)
 
-	f.Name = strings.ToLower(rf.Name)
-	f.Type = rf.Type.Name()
+		if skip {
+			continue
+		}
 
-	tag := rf.Tag
-	jsonData, ok := tag.Lookup("json")
+		// embedded structs without a json name are flattened, like encoding/json does
+		if rf.Anonymous && name == "" {
+			embedded := rf.Type
 
-	if ok {
-		tagSplit := strings.Split(jsonData, ",")
-		if len(tagSplit) > 0 {
-			if tagSplit[0] != "" {
-				f.Name = strings.TrimSpace(tagSplit[0])
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
 			}
+
+			if embedded.Kind() == reflect.Struct {
+				parseFields(embedded, seen, p)
+				continue
+			}
+		}
+
+		if !rf.IsExported() {
+			continue
 		}
+
+		p.Fields = append(p.Fields, parseField(rf, name, seen))
+	}
+}
+
+func parseField(rf reflect.StructField, name string, seen map[reflect.Type]bool) *Field {
+	f := &Field{}
+
+	f.Name = strings.ToLower(rf.Name)
+	f.Type = rf.Type.Name()
+
+	if name != "" {
+		f.Name = name
 	}
 
 	raw := rf.Type
 
-	if rf.Type.Kind() == reflect.Array || rf.Type.Kind() == reflect.Slice {
+	if raw.Kind() == reflect.Pointer {
+		raw = raw.Elem()
+		f.Type = raw.Name()
+	}
+
+	if raw.Kind() == reflect.Array || raw.Kind() == reflect.Slice {
 		f.Array = true
 		raw = raw.Elem()
 	}
 
-	if rf.Type.Kind() == reflect.Map {
+	if raw.Kind() == reflect.Map {
 		f.Map = true
 		raw = raw.Elem()
 	}
@@ -160,17 +346,21 @@ func parseField(rf reflect.StructField) *Field {
 		raw = raw.Elem()
 	}
 
-	if raw.Kind() == reflect.Struct {
+	if raw.Kind() == reflect.Struct && raw != timeType {
 		f.Type = "struct"
+		f.Entity = parseStruct(raw, seen)
+	}
 
-		it := reflect.New(raw)
+	return f
+}
 
-		if it.Kind() == reflect.Pointer {
-			it = it.Elem()
-		}
+// jsonName returns the json name of the field and if it's hidden from json
+func jsonName(rf reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(rf.Tag.Get("json"), ",")
 
-		f.Entity = parseStruct(it)
+	if name == "-" {
+		return "", true
 	}
 
-	return f
+	return strings.TrimSpace(name), false
 }
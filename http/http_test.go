@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Meduzz/quickapi/openapi"
+	"github.com/Meduzz/quickapi/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestOpenApiUsesConfigParams(t *testing.T) {
+	configurers := []Configurer{
+		WithWhereQueryMapStrategy("filter"),
+		WithTakeQueryIntStrategy("limit", 10),
+	}
+
+	engine := serve(t, configurers, guarded{})
+	res := do(engine, http.MethodGet, "/_openapi.json", "")
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	doc := &openapi.Document{}
+	err := json.Unmarshal(res.Body.Bytes(), doc)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	search := doc.Paths["/guarded/"]
+
+	if search == nil || search.Get == nil {
+		t.Fatalf("expected a search operation, got %v", doc.Paths)
+	}
+
+	names := make(map[string]bool)
+
+	for _, parameter := range search.Get.Parameters {
+		names[parameter.Name] = true
+	}
+
+	if !names["filter"] || !names["limit"] || names[WHERE] || names[TAKE] {
+		t.Fatalf("expected the configured parameter names, got %v", names)
+	}
+}
+
+func TestDefaultParamsMatchDefaultConfig(t *testing.T) {
+	actual := *DefaultConfig().Params
+	expected := *openapi.DefaultParams()
+
+	if actual != expected {
+		t.Fatalf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestForFillsInMissingFuncs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// ie a config built by hand, before the funcs were added
+	config := DefaultConfig()
+	WithStorage(storage.MemoryFactory)(config)
+	config.Principal = nil
+	config.Expand = nil
+	config.Aggregate = nil
+	config.Query = nil
+	config.Facets = nil
+
+	engine := gin.New()
+	err := For(nil, engine.Group(""), config, guarded{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{http.MethodPost, "/guarded/", `{"title":"good"}`, http.StatusCreated},
+		{http.MethodGet, "/guarded/1", "", http.StatusOK},
+		{http.MethodGet, "/guarded/", "", http.StatusOK},
+		{http.MethodGet, "/guarded/_aggregate", "", http.StatusNotImplemented}, // by memory storage
+		{http.MethodGet, "/guarded/_facets?field=title", "", http.StatusNotImplemented},
+	}
+
+	for _, request := range requests {
+		res := do(engine, request.method, request.path, request.body)
+
+		if res.Code != request.code {
+			t.Fatalf("%s %s: expected %d, got %d", request.method, request.path, request.code, res.Code)
+		}
+	}
+
+	if config.Principal != nil || config.Expand != nil || config.Aggregate != nil || config.Query != nil || config.Facets != nil {
+		t.Fatal("expected the config of the caller to be left as is")
+	}
+}
+
+func TestForWithoutStorageUsesGorm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one connection, every connection gets an in memory db of its own
+	pool, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetMaxOpenConns(1)
+
+	err = storage.AutoMigrate(db, guarded{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.Storage = nil
+
+	engine := gin.New()
+	err = For(db, engine.Group(""), config, guarded{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := do(engine, http.MethodPost, "/guarded/", `{"title":"good"}`)
+
+	if res.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", res.Code)
+	}
+
+	count := int64(0)
+	err = db.Table("guarded").Count(&count).Error
+
+	if err != nil || count != 1 {
+		t.Fatalf("expected the row in the db, got %d %v", count, err)
+	}
+}
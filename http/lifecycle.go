@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/gin-gonic/gin"
+)
+
+// hookContext creates the context handed to the lifecycle hooks and storage of the entity,
+// it's cancelled with the request or when the configured timeout of the operation passes.
+func (r *router) hookContext(ctx *gin.Context, operation model.Operation) (*model.Context, context.CancelFunc) {
+	var parent context.Context
+	var cancel context.CancelFunc
+
+	timeout := r.config.Timeout(r.entity.Name(), operation)
+
+	if timeout > 0 {
+		parent, cancel = context.WithTimeout(ctx.Request.Context(), timeout)
+	} else {
+		parent, cancel = context.WithCancel(ctx.Request.Context())
+	}
+
+	hctx := &model.Context{
+		Context:   parent,
+		Entity:    r.entity.Name(),
+		Operation: operation,
+		Principal: r.config.Principal(ctx),
+		Request:   ctx.Request,
+		Metadata:  make(map[string]any),
+	}
+
+	return hctx, cancel
+}
+
+func (r *router) beforeCreate(hctx *model.Context, entity any) error {
+	hook, ok := r.entity.(model.BeforeCreateSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnBeforeCreate(hctx, entity)
+}
+
+func (r *router) afterCreate(hctx *model.Context, entity any) error {
+	hook, ok := r.entity.(model.AfterCreateSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnAfterCreate(hctx, entity)
+}
+
+func (r *router) beforeRead(hctx *model.Context, id string) error {
+	hook, ok := r.entity.(model.BeforeReadSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnBeforeRead(hctx, id)
+}
+
+func (r *router) afterRead(hctx *model.Context, entity any) error {
+	hook, ok := r.entity.(model.AfterReadSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnAfterRead(hctx, entity)
+}
+
+func (r *router) beforeUpdate(hctx *model.Context, id string, entity any) error {
+	hook, ok := r.entity.(model.BeforeUpdateSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnBeforeUpdate(hctx, id, entity)
+}
+
+func (r *router) afterUpdate(hctx *model.Context, entity any) error {
+	hook, ok := r.entity.(model.AfterUpdateSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnAfterUpdate(hctx, entity)
+}
+
+func (r *router) beforeDelete(hctx *model.Context, id string) error {
+	hook, ok := r.entity.(model.BeforeDeleteSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnBeforeDelete(hctx, id)
+}
+
+func (r *router) afterDelete(hctx *model.Context, id string) error {
+	hook, ok := r.entity.(model.AfterDeleteSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnAfterDelete(hctx, id)
+}
+
+func (r *router) beforeSearch(hctx *model.Context, where map[string]string) error {
+	hook, ok := r.entity.(model.BeforeSearchSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnBeforeSearch(hctx, where)
+}
+
+func (r *router) afterSearch(hctx *model.Context, data any) error {
+	hook, ok := r.entity.(model.AfterSearchSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnAfterSearch(hctx, data)
+}
+
+func (r *router) beforePatch(hctx *model.Context, id string, data map[string]any) error {
+	hook, ok := r.entity.(model.BeforePatchSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnBeforePatch(hctx, id, data)
+}
+
+func (r *router) afterPatch(hctx *model.Context, entity any) error {
+	hook, ok := r.entity.(model.AfterPatchSupport)
+
+	if !ok {
+		return nil
+	}
+
+	return hook.OnAfterPatch(hctx, entity)
+}
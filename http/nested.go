@@ -0,0 +1,380 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormschema "gorm.io/gorm/schema"
+)
+
+type (
+	// nested serves the children of a relation under the owner, ie /persons/:id/pets
+	nested struct {
+		*router
+		db       *gorm.DB
+		relation *gormschema.Relationship
+	}
+)
+
+// CHILD is the path param of a child in nested routes
+const CHILD = "child"
+
+// nestedRoutes registers routes for the child relations (has one, has many & many to many) of the entity.
+// Children are listed when the owner can be read, and changed when it can be updated.
+// They are read and written straight from the db, so only entities stored with gorm get them, true when the entity got them.
+func nestedRoutes(r *router, api *gin.RouterGroup, spec *Spec) (bool, error) {
+	db := storage.DB(r.storage)
+
+	if db == nil || model.KindOf(r.entity) != model.KindNormal {
+		return false, nil
+	}
+
+	sch, err := model.Schema(r.entity)
+
+	if err != nil {
+		return false, err
+	}
+
+	read := model.Enabled(r.entity, model.OperationRead)
+	update := model.Enabled(r.entity, model.OperationUpdate)
+
+	for _, relation := range spec.Relations {
+		if relation.Type == string(gormschema.BelongsTo) {
+			continue
+		}
+
+		n := &nested{r, db, sch.Relationships.Relations[relation.Name]}
+		field := relation.Field
+
+		if field == "" {
+			// like encoding/json does
+			field = relation.Name
+		}
+
+		path := fmt.Sprintf("/:id/%s", field)
+
+		if read {
+			api.GET(path, n.List)
+		}
+
+		if update {
+			api.POST(path, n.Create)
+			api.DELETE(fmt.Sprintf("%s/:%s", path, CHILD), n.Delete)
+
+			if relation.Type == relationType(gormschema.Many2Many) {
+				api.PUT(fmt.Sprintf("%s/:%s", path, CHILD), n.Attach)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// List responds with the children of the owner, the read hooks of the owner apply
+func (n *nested) List(ctx *gin.Context) {
+	id, err := n.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := n.hookContext(ctx, model.OperationRead)
+	defer cancel()
+
+	err = n.beforeRead(hctx, id)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
+	owner, err := n.owner(hctx, ctx, id)
+
+	if err != nil {
+		println("reading owner threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(err))
+		return
+	}
+
+	err = n.afterRead(hctx, owner)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
+	children := reflect.New(n.relation.Field.FieldType)
+	err = n.association(hctx, owner, false).Find(children.Interface())
+
+	if err != nil {
+		println("reading children threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(err))
+		return
+	}
+
+	ctx.JSON(200, children.Interface())
+}
+
+// Create creates a child of the owner, many to many children are also linked to it. It's an update of the owner.
+func (n *nested) Create(ctx *gin.Context) {
+	id, err := n.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	child, err := n.config.Body(n.child(), ctx)
+
+	if err != nil {
+		println("binding body threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := n.hookContext(ctx, model.OperationUpdate)
+	defer cancel()
+
+	owner, ok := n.beforeChange(hctx, ctx, id)
+
+	if !ok {
+		return
+	}
+
+	// uuids and ulids are generated, natural keys are required
+	key, err := model.KeyOfSchema(n.relation.FieldSchema)
+
+	if err == nil {
+		err = key.Assign(hctx, child)
+
+		if err != nil {
+			err = fmt.Errorf("%w: %s", herror.ErrBadRequest, err)
+		}
+	}
+
+	if err == nil {
+		err = n.association(hctx, owner, true).Append(child)
+	}
+
+	if err != nil {
+		println("creating child threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(err))
+		return
+	}
+
+	n.afterChange(hctx, id, owner)
+	ctx.JSON(http.StatusCreated, child)
+}
+
+// Attach links an existing child to the owner (many to many). It's an update of the owner.
+func (n *nested) Attach(ctx *gin.Context) {
+	id, err := n.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := n.hookContext(ctx, model.OperationUpdate)
+	defer cancel()
+
+	owner, ok := n.beforeChange(hctx, ctx, id)
+
+	if !ok {
+		return
+	}
+
+	child := n.child()
+	childKey, err := n.childKey(ctx)
+
+	if err == nil {
+		err = n.db.WithContext(hctx).Where(childKey).First(child).Error
+	}
+
+	if err != nil {
+		println("reading child threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(notFound(err)))
+		return
+	}
+
+	err = n.association(hctx, owner, true).Append(child)
+
+	if err != nil {
+		println("attaching child threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(err))
+		return
+	}
+
+	n.afterChange(hctx, id, owner)
+	ctx.JSON(200, child)
+}
+
+// Delete deletes a child of the owner, many to many children are only detached. It's an update of the owner.
+func (n *nested) Delete(ctx *gin.Context) {
+	id, err := n.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := n.hookContext(ctx, model.OperationUpdate)
+	defer cancel()
+
+	owner, ok := n.beforeChange(hctx, ctx, id)
+
+	if !ok {
+		return
+	}
+
+	// only children of the owner
+	child := n.child()
+	childKey, err := n.childKey(ctx)
+
+	if err == nil {
+		err = n.association(hctx, owner, false).Find(child, childKey)
+	}
+
+	if err == nil && reflect.ValueOf(child).Elem().IsZero() {
+		err = herror.ErrNotFound
+	}
+
+	if err != nil {
+		println("reading child threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(err))
+		return
+	}
+
+	association := n.association(hctx, owner, false)
+
+	if n.relation.Type != gormschema.Many2Many {
+		association = association.Unscoped()
+	}
+
+	err = association.Delete(child)
+
+	if err != nil {
+		println("deleting child threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(err))
+		return
+	}
+
+	n.afterChange(hctx, id, owner)
+	ctx.Status(200)
+}
+
+// beforeChange reads the owner and runs its before update hook, false when the request was aborted
+func (n *nested) beforeChange(hctx *model.Context, ctx *gin.Context, id string) (any, bool) {
+	owner, err := n.owner(hctx, ctx, id)
+
+	if err != nil {
+		println("reading owner threw error", err.Error())
+		ctx.AbortWithStatus(codeFromError(err))
+		return nil, false
+	}
+
+	err = n.beforeUpdate(hctx, id, owner)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return nil, false
+	}
+
+	return owner, true
+}
+
+// afterChange runs the after update hook of the owner and emits the update
+func (n *nested) afterChange(hctx *model.Context, id string, owner any) {
+	// the change is saved, so the after hook can no longer veto it
+	err := n.afterUpdate(hctx, owner)
+
+	if err != nil {
+		println("after update hook threw error", err.Error())
+	}
+
+	n.emit(hctx, model.OperationUpdate, id, owner)
+}
+
+// owner reads the owner by id, with the named filters of the request
+func (n *nested) owner(hctx *model.Context, ctx *gin.Context, id string) (any, error) {
+	owner := n.entity.Create()
+	pk, err := model.KeyOf(n.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := pk.Parse(id)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", herror.ErrBadRequest, err)
+	}
+
+	query := n.db.
+		WithContext(hctx).
+		Table(n.entity.Name())
+
+	slice.ForEach(CreateHooks(n.entity, ctx), func(hook model.Hook) {
+		query = query.Scopes(hook)
+	})
+
+	err = query.Where(pk.Where(n.entity.Name(), value)).First(owner).Error
+
+	return owner, notFound(err)
+}
+
+// association is the relation of owner. Appending children saves the owner (ie its updated_at), which must
+// go to the table of the entity, the other association queries are built on the child and must not inherit it.
+func (n *nested) association(hctx *model.Context, owner any, appending bool) *gorm.Association {
+	db := n.db.WithContext(hctx)
+
+	if appending {
+		db = db.Table(n.entity.Name())
+	}
+
+	return db.Model(owner).Association(n.relation.Name)
+}
+
+// child creates a new *T of the relation
+func (n *nested) child() any {
+	return reflect.New(n.relation.FieldSchema.ModelType).Interface()
+}
+
+// childKey is the condition on the primary key of the child in the path
+func (n *nested) childKey(ctx *gin.Context) (clause.Expression, error) {
+	pk, err := model.KeyOfSchema(n.relation.FieldSchema)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := pk.Parse(ctx.Param(CHILD))
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", herror.ErrBadRequest, err)
+	}
+
+	return pk.Where(n.relation.FieldSchema.Table, id), nil
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return herror.ErrNotFound
+	}
+
+	return err
+}
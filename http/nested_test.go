@@ -0,0 +1,303 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/openapi"
+	"github.com/Meduzz/quickapi/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type (
+	// family is kept in households, not in the table gorm would pick
+	family struct {
+		ID        int64     `json:"id"`
+		Title     string    `json:"title"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		Members   []*member `gorm:"foreignKey:FamilyID" json:"members,omitempty"`
+		Labels    []*label  `gorm:"many2many:household_labels" json:"labels,omitempty"`
+	}
+
+	// member has a generated key
+	member struct {
+		Code     string `gorm:"primaryKey" json:"code"`
+		Name     string `json:"name"`
+		FamilyID int64  `json:"-"`
+	}
+
+	label struct {
+		ID   int64  `json:"id"`
+		Text string `json:"text"`
+	}
+)
+
+func (family) Name() string                    { return "households" }
+func (family) Create() any                     { return &family{} }
+func (family) CreateArray() any                { return make([]*family, 0) }
+func (member) Key() (string, model.IDStrategy) { return "Code", model.IDULID }
+
+func (family) OnBeforeUpdate(ctx *model.Context, id string, entity any) error {
+	if entity.(*family).Title == "locked" {
+		return model.Veto(http.StatusForbidden, "the family is locked")
+	}
+
+	return nil
+}
+
+func (family) OnAfterRead(ctx *model.Context, entity any) error {
+	if entity.(*family).Title == "secret" {
+		return model.Veto(http.StatusForbidden, "the family is secret")
+	}
+
+	return nil
+}
+
+// serveFamilies sets up the routes of families on an in memory sqlite db, seeded with the families a, b, locked & secret and the labels x & y
+func serveFamilies(t *testing.T, configurers ...Configurer) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one connection, every connection gets an in memory db of its own
+	pool, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetMaxOpenConns(1)
+
+	err = db.AutoMigrate(&member{}, &label{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.AutoMigrate(db, family{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"a", "b", "locked", "secret"} {
+		err = db.Table(family{}.Name()).Create(&family{Title: title}).Error
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = db.Create([]*label{{Text: "x"}, {Text: "y"}}).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+
+	for _, configurer := range configurers {
+		configurer(config)
+	}
+
+	engine := gin.New()
+	err = For(db, engine.Group(""), config, family{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return engine, db
+}
+
+func TestNestedHasMany(t *testing.T) {
+	events := make([]*model.Event, 0)
+	engine, db := serveFamilies(t, WithEventListener(func(ctx context.Context, event *model.Event) {
+		events = append(events, event)
+	}))
+
+	created := make(map[string]*member)
+
+	for _, it := range []struct{ family, name string }{{"1", "ann"}, {"1", "bob"}, {"2", "cid"}} {
+		res := do(engine, http.MethodPost, "/households/"+it.family+"/members", `{"name":"`+it.name+`"}`)
+
+		if res.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", res.Code)
+		}
+
+		child := &member{}
+		err := json.Unmarshal(res.Body.Bytes(), child)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(child.Code) != 26 {
+			t.Fatalf("expected a ulid, got %q", child.Code)
+		}
+
+		created[it.name] = child
+	}
+
+	if len(events) != 3 || events[0].Operation != model.OperationUpdate || events[0].ID != "1" || events[0].Entity != "households" {
+		t.Fatalf("expected an update of households 1 per child, got %v", events)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"create for a missing family", http.MethodPost, "/households/9/members", `{"name":"x"}`, 404},
+		{"create for a locked family", http.MethodPost, "/households/3/members", `{"name":"x"}`, 403},
+		{"create with a bad family id", http.MethodPost, "/households/x/members", `{"name":"x"}`, 400},
+		{"list a secret family", http.MethodGet, "/households/4/members", "", 403},
+		{"list a missing family", http.MethodGet, "/households/9/members", "", 404},
+		{"delete a child of another family", http.MethodDelete, "/households/1/members/" + created["cid"].Code, "", 404},
+		{"delete with a bad child id", http.MethodDelete, "/households/1/members/x", "", 400},
+		{"delete from a locked family", http.MethodDelete, "/households/3/members/" + created["ann"].Code, "", 403},
+		{"delete a child", http.MethodDelete, "/households/1/members/" + created["ann"].Code, "", 200},
+		{"delete it again", http.MethodDelete, "/households/1/members/" + created["ann"].Code, "", 404},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(engine, test.method, test.path, test.body)
+
+			if res.Code != test.code {
+				t.Fatalf("expected %d, got %d", test.code, res.Code)
+			}
+		})
+	}
+
+	res := do(engine, http.MethodGet, "/households/1/members", "")
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	members := make([]*member, 0)
+	err := json.Unmarshal(res.Body.Bytes(), &members)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(members) != 1 || members[0].Name != "bob" {
+		t.Fatalf("expected bob, got %v", members)
+	}
+
+	count := int64(0)
+	err = db.Model(&member{}).Count(&count).Error
+
+	if err != nil || count != 2 {
+		t.Fatalf("expected bob and cid to be left, got %d %v", count, err)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("expected an update event for the delete only, got %d events", len(events))
+	}
+}
+
+func TestNestedManyToMany(t *testing.T) {
+	engine, db := serveFamilies(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"attach", http.MethodPut, "/households/1/labels/1", "", 200},
+		{"attach again", http.MethodPut, "/households/1/labels/1", "", 200},
+		{"attach to another family", http.MethodPut, "/households/2/labels/2", "", 200},
+		{"attach a missing label", http.MethodPut, "/households/1/labels/9", "", 404},
+		{"attach to a locked family", http.MethodPut, "/households/3/labels/1", "", 403},
+		{"create", http.MethodPost, "/households/1/labels", `{"text":"z"}`, 201},
+		{"detach a label of another family", http.MethodDelete, "/households/1/labels/2", "", 404},
+		{"detach", http.MethodDelete, "/households/1/labels/1", "", 200},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(engine, test.method, test.path, test.body)
+
+			if res.Code != test.code {
+				t.Fatalf("expected %d, got %d", test.code, res.Code)
+			}
+		})
+	}
+
+	res := do(engine, http.MethodGet, "/households/1/labels", "")
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", res.Code)
+	}
+
+	labels := make([]*label, 0)
+	err := json.Unmarshal(res.Body.Bytes(), &labels)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(labels) != 1 || labels[0].Text != "z" {
+		t.Fatalf("expected z, got %v", labels)
+	}
+
+	// detached labels are kept
+	count := int64(0)
+	err = db.Model(&label{}).Count(&count).Error
+
+	if err != nil || count != 3 {
+		t.Fatalf("expected 3 labels, got %d %v", count, err)
+	}
+}
+
+func TestNestedOnlyWithGorm(t *testing.T) {
+	gormEngine, _ := serveFamilies(t)
+
+	tests := []struct {
+		name   string
+		engine *gin.Engine
+		nested bool
+	}{
+		{"gorm", gormEngine, true},
+		{"memory", serve(t, nil, family{}), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(test.engine, http.MethodGet, "/households/1/members", "")
+
+			if routed := res.Code != http.StatusNotFound; routed != test.nested {
+				t.Fatalf("expected nested routes %v, got %d", test.nested, res.Code)
+			}
+
+			doc := &openapi.Document{}
+			err := json.Unmarshal(do(test.engine, http.MethodGet, "/_openapi.json", "").Body.Bytes(), doc)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, documented := doc.Paths["/households/{id}/members"]; documented != test.nested {
+				t.Fatalf("expected nested routes documented %v", test.nested)
+			}
+		})
+	}
+}
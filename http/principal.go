@@ -0,0 +1,19 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ClientCertPrincipal creates a middleware that sets the subject of a verified client cert as the PRINCIPAL, ie CN=orders,O=acme.
+// It's handed to lifecycle hooks with the default config.
+func ClientCertPrincipal() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		state := ctx.Request.TLS
+
+		if state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+			ctx.Set(PRINCIPAL, state.VerifiedChains[0][0].Subject.String())
+		}
+
+		ctx.Next()
+	}
+}
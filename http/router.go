@@ -1,9 +1,13 @@
 package http
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/Meduzz/helper/fp/slice"
 	"github.com/Meduzz/helper/http/herror"
 	"github.com/Meduzz/quickapi/api"
 	"github.com/Meduzz/quickapi/model"
@@ -20,10 +24,21 @@ type (
 	}
 )
 
-func newRouter(db *gorm.DB, config *Config, entity model.Entity) *router {
-	store := storage.CreateStorage(db, entity)
+func newRouter(db *gorm.DB, config *Config, entity model.Entity) (*router, error) {
+	store, err := config.Storage(db, entity)
 
-	return &router{store, entity, config}
+	if err != nil {
+		return nil, err
+	}
+
+	// a key that doesn't fit its strategy is a programming error
+	_, err = model.KeyOf(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &router{store, entity, config}, nil
 }
 
 func (r *router) Create(ctx *gin.Context) {
@@ -35,40 +50,97 @@ func (r *router) Create(ctx *gin.Context) {
 		return
 	}
 
+	hctx, cancel := r.hookContext(ctx, model.OperationCreate)
+	defer cancel()
+
+	err = r.beforeCreate(hctx, entity)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
 	req := api.NewCreate(entity)
-	entity, err = r.storage.Create(req)
+	entity, err = r.storage.Create(hctx, req)
 
 	if err != nil {
 		println("creating row threw error", err.Error())
-		code := herror.CodeFromError(err)
+		code := codeFromError(err)
 
 		ctx.AbortWithStatus(code)
 		return
 	}
 
+	// the change is saved, so the after hook can no longer veto it
+	err = r.afterCreate(hctx, entity)
+
+	if err != nil {
+		println("after create hook threw error", err.Error())
+	}
+
+	r.emit(hctx, model.OperationCreate, "", entity)
 	ctx.JSON(http.StatusCreated, entity)
 }
 
 func (r *router) Read(ctx *gin.Context) {
-	id := r.config.ID(ctx)
+	id, err := r.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
 	preload := r.config.Preload(ctx)
+	expand, err := r.expansions(ctx, model.OperationRead)
+
+	if err != nil {
+		println("resolving expand threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := r.hookContext(ctx, model.OperationRead)
+	defer cancel()
+
+	err = r.beforeRead(hctx, id)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
 
 	req := api.NewRead(id, preload)
-	entity, err := r.storage.Read(req)
+	req.Expand = expand
+	entity, err := r.storage.Read(hctx, req)
 
 	if err != nil {
 		println("reading row threw error", err.Error())
-		code := herror.CodeFromError(err)
+		code := codeFromError(err)
 
 		ctx.AbortWithStatus(code)
 		return
 	}
 
+	err = r.afterRead(hctx, entity)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
 	ctx.JSON(200, entity)
 }
 
 func (r *router) Update(ctx *gin.Context) {
-	id := r.config.ID(ctx)
+	id, err := r.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
 	entity, err := r.config.Body(r.entity.Create(), ctx)
 
 	if err != nil {
@@ -77,37 +149,80 @@ func (r *router) Update(ctx *gin.Context) {
 		return
 	}
 
+	hctx, cancel := r.hookContext(ctx, model.OperationUpdate)
+	defer cancel()
+
+	err = r.beforeUpdate(hctx, id, entity)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
 	hooks := CreateHooks(r.entity, ctx)
 
 	req := api.NewUpate(id, entity, hooks)
-	entity, err = r.storage.Update(req)
+	entity, err = r.storage.Update(hctx, req)
 
 	if err != nil {
 		println("updating row threw error", err.Error())
-		code := herror.CodeFromError(err)
+		code := codeFromError(err)
 
 		ctx.AbortWithStatus(code)
 		return
 	}
 
+	// the change is saved, so the after hook can no longer veto it
+	err = r.afterUpdate(hctx, entity)
+
+	if err != nil {
+		println("after update hook threw error", err.Error())
+	}
+
+	r.emit(hctx, model.OperationUpdate, id, entity)
 	ctx.JSON(200, entity)
 }
 
 func (r *router) Delete(ctx *gin.Context) {
-	id := r.config.ID(ctx)
+	id, err := r.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := r.hookContext(ctx, model.OperationDelete)
+	defer cancel()
+
+	err = r.beforeDelete(hctx, id)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
 	hooks := CreateHooks(r.entity, ctx)
 
 	req := api.NewDelete(id, hooks)
-	err := r.storage.Delete(req)
+	err = r.storage.Delete(hctx, req)
 
 	if err != nil {
 		println("deleting row threw error", err.Error())
-		code := herror.CodeFromError(err)
+		code := codeFromError(err)
 
 		ctx.AbortWithStatus(code)
 		return
 	}
 
+	// the change is saved, so the after hook can no longer veto it
+	err = r.afterDelete(hctx, id)
+
+	if err != nil {
+		println("after delete hook threw error", err.Error())
+	}
+
+	r.emit(hctx, model.OperationDelete, id, nil)
 	ctx.Status(200)
 }
 
@@ -116,12 +231,39 @@ func (r *router) Search(ctx *gin.Context) {
 	skip := r.config.Skip(ctx)
 	where := r.config.Where(ctx)
 	sort := r.config.Sorting(ctx)
+	expand, err := r.expansions(ctx, model.OperationSearch)
+
+	if err != nil {
+		println("resolving expand threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	query, err := r.fullText(ctx)
+
+	if err != nil {
+		println("reading full text search threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := r.hookContext(ctx, model.OperationSearch)
+	defer cancel()
+
+	err = r.beforeSearch(hctx, where)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
 
 	preload := r.config.Preload(ctx)
 	hooks := CreateHooks(r.entity, ctx)
 
 	req := api.NewSearch(skip, take, where, sort, preload, hooks)
-	data, err := r.storage.Search(req)
+	req.Expand = expand
+	req.Query = query
+	data, err := r.storage.Search(hctx, req)
 
 	if err != nil {
 		println("searching for data threw error", err.Error())
@@ -131,19 +273,71 @@ func (r *router) Search(ctx *gin.Context) {
 			return
 		}
 
-		code := herror.CodeFromError(err)
+		code := codeFromError(err)
 
 		ctx.AbortWithStatus(code)
 		return
 	}
 
+	err = r.afterSearch(hctx, data)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
 	ctx.JSON(200, data)
 }
 
+// id reads the id of the request, validated and normalized (ie lower case uuids) by the key of the entity
+func (r *router) id(ctx *gin.Context) (string, error) {
+	key, err := model.KeyOf(r.entity)
+
+	if err != nil {
+		return "", err
+	}
+
+	id, err := key.Parse(r.config.ID(ctx))
+
+	if err != nil {
+		return "", err
+	}
+
+	return key.Format(id), nil
+}
+
+// fullText reads the full text search, entities without searchable columns can't be searched
+func (r *router) fullText(ctx *gin.Context) (string, error) {
+	query := r.config.Query(ctx)
+
+	if query == "" {
+		return "", nil
+	}
+
+	searchable, err := model.SearchableOf(r.entity)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(searchable) == 0 {
+		return "", fmt.Errorf("%s has no searchable columns", r.entity.Name())
+	}
+
+	return query, nil
+}
+
 func (r *router) Patch(ctx *gin.Context) {
-	id := r.config.ID(ctx)
+	id, err := r.id(ctx)
+
+	if err != nil {
+		println("parsing id threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
 	data := make(map[string]any)
-	err := ctx.BindJSON(&data)
+	err = ctx.BindJSON(&data)
 
 	if err != nil {
 		println("binding request threw error", err.Error())
@@ -151,11 +345,29 @@ func (r *router) Patch(ctx *gin.Context) {
 		return
 	}
 
+	err = r.validatePatch(data)
+
+	if err != nil {
+		println("validating request threw error", err.Error())
+		ctx.AbortWithStatus(400)
+		return
+	}
+
+	hctx, cancel := r.hookContext(ctx, model.OperationPatch)
+	defer cancel()
+
+	err = r.beforePatch(hctx, id, data)
+
+	if err != nil {
+		abortWithHookError(ctx, err)
+		return
+	}
+
 	preload := r.config.Preload(ctx)
 	hooks := CreateHooks(r.entity, ctx)
 
 	req := api.NewPatch(id, data, preload, hooks)
-	entity, err := r.storage.Patch(req)
+	entity, err := r.storage.Patch(hctx, req)
 
 	if err != nil {
 		println("patching data threw error", err.Error())
@@ -165,11 +377,61 @@ func (r *router) Patch(ctx *gin.Context) {
 			return
 		}
 
-		code := herror.CodeFromError(err)
+		code := codeFromError(err)
 
 		ctx.AbortWithStatus(code)
 		return
 	}
 
+	// the change is saved, so the after hook can no longer veto it
+	err = r.afterPatch(hctx, entity)
+
+	if err != nil {
+		println("after patch hook threw error", err.Error())
+	}
+
+	r.emit(hctx, model.OperationPatch, id, entity)
 	ctx.JSON(200, entity)
 }
+
+// emit hands a change event to all configured listeners, with the values of ctx.
+// The change is saved, so the event is not cancelled with the request.
+func (r *router) emit(ctx context.Context, operation model.Operation, id string, data any) {
+	if len(r.config.Listeners) == 0 {
+		return
+	}
+
+	event := model.NewEvent(r.entity.Name(), operation, id, data)
+	ctx = context.WithoutCancel(ctx)
+
+	slice.ForEach(r.config.Listeners, func(listener EventListener) {
+		listener(ctx, event)
+	})
+}
+
+// codeFromError turns timeouts and cancelled requests into statuses, other errors are handled by herror
+func codeFromError(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return 499 // client closed request
+	}
+
+	return herror.CodeFromError(err)
+}
+
+// abortWithHookError responds with the status and reason from a model.Veto, or 500 for other errors
+func abortWithHookError(ctx *gin.Context, err error) {
+	println("lifecycle hook threw error", err.Error())
+	code := codeFromError(err)
+	veto := herror.HttpError{}
+
+	if errors.As(err, &veto) {
+		ctx.AbortWithStatusJSON(code, veto)
+		return
+	}
+
+	ctx.AbortWithStatus(code)
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type guarded struct {
+	ID    int64  `json:"id,omitempty"`
+	Title string `json:"title"`
+}
+
+func (guarded) Name() string     { return "guarded" }
+func (guarded) Create() any      { return &guarded{} }
+func (guarded) CreateArray() any { return make([]*guarded, 0) }
+
+func (guarded) OnBeforeCreate(ctx *model.Context, entity any) error {
+	if entity.(*guarded).Title == "bad" {
+		return model.Veto(http.StatusForbidden, "bad names are not allowed")
+	}
+
+	return nil
+}
+
+func (guarded) OnAfterCreate(ctx *model.Context, entity any) error {
+	return errors.New("after create failed")
+}
+
+// serve sets up the routes of the entities on memory storage, the config is customized by configurers
+func serve(t *testing.T, configurers []Configurer, entities ...model.Entity) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	config := DefaultConfig()
+	WithStorage(storage.MemoryFactory)(config)
+
+	for _, configurer := range configurers {
+		configurer(config)
+	}
+
+	engine := gin.New()
+	err := For(nil, engine.Group(""), config, entities...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return engine
+}
+
+func do(engine *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	res := httptest.NewRecorder()
+	engine.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestVetoRespondsWithReason(t *testing.T) {
+	engine := serve(t, nil, guarded{})
+	res := do(engine, http.MethodPost, "/guarded/", `{"title":"bad"}`)
+
+	if res.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", res.Code)
+	}
+
+	veto := herror.HttpError{}
+	err := json.Unmarshal(res.Body.Bytes(), &veto)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if veto.Message != "bad names are not allowed" {
+		t.Fatalf("expected the reason in the body, got %s", res.Body.String())
+	}
+}
+
+func TestAfterHookCantVeto(t *testing.T) {
+	events := make([]*model.Event, 0)
+	listener := WithEventListener(func(ctx context.Context, event *model.Event) {
+		events = append(events, event)
+	})
+
+	engine := serve(t, []Configurer{listener}, guarded{})
+	res := do(engine, http.MethodPost, "/guarded/", `{"title":"good"}`)
+
+	if res.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", res.Code)
+	}
+
+	if len(events) != 1 || events[0].Operation != model.OperationCreate {
+		t.Fatalf("expected a create event, got %v", events)
+	}
+
+	res = do(engine, http.MethodGet, "/guarded/1", "")
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected the entity to be saved, got %d", res.Code)
+	}
+}
+
+// filtered has a named filter, that the memory storage can't apply
+type filtered struct {
+	ID  int64 `json:"id,omitempty"`
+	Age int   `json:"age"`
+}
+
+func (filtered) Name() string     { return "filtered" }
+func (filtered) Create() any      { return &filtered{} }
+func (filtered) CreateArray() any { return make([]*filtered, 0) }
+func (filtered) Scopes() []*model.NamedFilter {
+	return []*model.NamedFilter{model.NewFilter("older", func(data map[string]string) model.Hook {
+		return func(db *gorm.DB) *gorm.DB {
+			return db.Where("age > ?", data["than"])
+		}
+	})}
+}
+
+func TestMemoryStorageRejectsFilters(t *testing.T) {
+	engine := serve(t, nil, filtered{})
+	do(engine, http.MethodPost, "/filtered/", `{"age":3}`)
+
+	res := do(engine, http.MethodGet, "/filtered/?older[than]=5", "")
+
+	if res.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 with a filter, got %d", res.Code)
+	}
+
+	res = do(engine, http.MethodGet, "/filtered/", "")
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected 200 without a filter, got %d", res.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/schema"
+)
+
+var schemas sync.Map // reflect.Type -> *schema.Schema
+
+// schemaOf returns the json schema document of t, they are only built once
+func schemaOf(t reflect.Type) *schema.Schema {
+	if it, ok := schemas.Load(t); ok {
+		return it.(*schema.Schema)
+	}
+
+	it, _ := schemas.LoadOrStore(t, schema.TypeDocument(t))
+
+	return it.(*schema.Schema)
+}
+
+// decodeDocument decodes json the way the validator expects it
+func decodeDocument(bs []byte) (any, error) {
+	var document any
+	decoder := json.NewDecoder(bytes.NewReader(bs))
+	err := decoder.Decode(&document)
+
+	return document, err
+}
+
+// validatePatch validates the patch data against the json schema of the entity,
+// required properties are not enforced since a patch only carries the changes.
+func (r *router) validatePatch(data map[string]any) error {
+	if !r.config.Validate {
+		return nil
+	}
+
+	document, err := r.patchDocument(data)
+
+	if err != nil {
+		return err
+	}
+
+	return schemaOf(reflect.TypeOf(r.entity.Create())).ValidatePartial(document, true)
+}
+
+// patchDocument renames the columns of a patch into their json names,
+// json entities are patched by json name already. Unknown columns are left for the storage to reject.
+func (r *router) patchDocument(data map[string]any) (any, error) {
+	bs, err := json.Marshal(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := decodeDocument(bs)
+
+	if err != nil || model.KindOf(r.entity) == model.KindJson {
+		return document, err
+	}
+
+	sch, err := model.Schema(r.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	renamed := make(map[string]any)
+
+	for column, value := range document.(map[string]any) {
+		field := sch.LookUpField(column)
+
+		if field == nil {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.StructField.Tag.Get("json"), ",")
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		renamed[name] = value
+	}
+
+	return renamed, nil
+}
@@ -0,0 +1,47 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+type validated struct {
+	ID    int64  `json:"id,omitempty"`
+	Email string `json:"email" binding:"required,email"`
+	Age   int    `json:"age" binding:"gte=0"`
+}
+
+func (validated) Name() string     { return "validated" }
+func (validated) Create() any      { return &validated{} }
+func (validated) CreateArray() any { return make([]*validated, 0) }
+
+func TestSchemaValidation(t *testing.T) {
+	engine := serve(t, []Configurer{WithSchemaValidation()}, validated{})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"create", http.MethodPost, "/validated/", `{"email":"a@b.se","age":3}`, 201},
+		{"create without required", http.MethodPost, "/validated/", `{"age":3}`, 400},
+		{"create with a bad email", http.MethodPost, "/validated/", `{"email":"nope"}`, 400},
+		{"create with a string for a number", http.MethodPost, "/validated/", `{"email":"a@b.se","age":"3"}`, 400},
+		{"create with broken json", http.MethodPost, "/validated/", `{"email":`, 400},
+		{"update below the minimum", http.MethodPut, "/validated/1", `{"email":"a@b.se","age":-1}`, 400},
+		{"patch without required", http.MethodPatch, "/validated/1", `{"age":4}`, 200},
+		{"patch below the minimum", http.MethodPatch, "/validated/1", `{"age":-1}`, 400},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := do(engine, test.method, test.path, test.body)
+
+			if res.Code != test.code {
+				t.Fatalf("expected %d, got %d", test.code, res.Code)
+			}
+		})
+	}
+}
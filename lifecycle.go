@@ -0,0 +1,117 @@
+package quickapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Meduzz/helper/fp/slice"
+)
+
+type (
+	// component is started with the server and returns how to stop it
+	component func(ctx context.Context) func(ctx context.Context) error
+
+	// shutdowner is a server that can drain gracefully, or be closed when that takes too long
+	shutdowner interface {
+		Shutdown(ctx context.Context) error
+		Close() error
+	}
+)
+
+// Background runs run while the server is up, its ctx is cancelled on shutdown, ie webhook.Webhooks.Run.
+// Components are stopped in the reverse order they were added, after the server drained.
+func (s *Starter) Background(run func(ctx context.Context) error) *Starter {
+	s.components = append(s.components, func(ctx context.Context) func(ctx context.Context) error {
+		// cancelled by shutdown, not by ctx, so the order is kept
+		ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			err := run(ctx)
+
+			if err != nil {
+				println("background component threw error", err.Error())
+			}
+		}()
+
+		return func(drain context.Context) error {
+			cancel()
+
+			select {
+			case <-done:
+				return nil
+			case <-drain.Done():
+				return drain.Err()
+			}
+		}
+	})
+
+	return s
+}
+
+// OnShutdown calls stop on shutdown, ie to flush a cache, in reverse order with the background components
+func (s *Starter) OnShutdown(stop func(ctx context.Context) error) *Starter {
+	s.components = append(s.components, func(context.Context) func(ctx context.Context) error {
+		return stop
+	})
+
+	return s
+}
+
+// start starts the components, in order
+func (s *Starter) start(ctx context.Context) []func(ctx context.Context) error {
+	stops := make([]func(ctx context.Context) error, 0, len(s.components))
+
+	for _, it := range s.components {
+		stops = append(stops, it(ctx))
+	}
+
+	return stops
+}
+
+// shutdown drains the servers, stops the components in reverse order and closes the db, all within drain
+func (s *Starter) shutdown(servers []shutdowner, stops []func(ctx context.Context) error, drain Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(drain))
+	defer cancel()
+
+	errorz := slice.Map(servers, func(server shutdowner) error {
+		err := server.Shutdown(ctx)
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			println("draining threw error", err.Error(), "closing remaining connections")
+			return server.Close()
+		}
+
+		return err
+	})
+
+	for i := len(stops) - 1; i >= 0; i-- {
+		errorz = append(errorz, stops[i](ctx))
+	}
+
+	errorz = append(errorz, s.close())
+
+	return errors.Join(errorz...)
+}
+
+// close closes the db pool when the starter opened it, a db given to the starter is left to its owner
+func (s *Starter) close() error {
+	if s.db == nil || !s.opened {
+		return nil
+	}
+
+	pool, err := s.db.DB()
+
+	if err != nil {
+		return err
+	}
+
+	// opened again by the next Serve
+	s.db = nil
+	s.opened = false
+
+	return pool.Close()
+}
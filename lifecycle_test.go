@@ -0,0 +1,60 @@
+package quickapi
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestCloseLeavesGivenDbOpen(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	starter := NewStarter(db, thing{})
+	err = starter.connect(DefaultSettings())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = starter.shutdown(nil, nil, DefaultSettings().Drain)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec("SELECT 1").Error
+
+	if err != nil {
+		t.Fatalf("expected the given db to be open, got %v", err)
+	}
+}
+
+func TestCloseClosesOpenedDb(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Database.DSN = "sqlite://file::memory:"
+
+	starter := NewStarter(nil, thing{})
+	err := starter.connect(settings)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := starter.db
+	err = starter.shutdown(nil, nil, settings.Drain)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Exec("SELECT 1").Error
+
+	if err == nil {
+		t.Fatal("expected the opened db to be closed")
+	}
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/storage"
+	"gorm.io/gorm"
+)
+
+type (
+	// recorder reads from the db, but records the statements that would change it
+	recorder struct {
+		gorm.ConnPool
+		dialector  gorm.Dialector
+		statements []string
+	}
+)
+
+// Diff compares the entities (and their child collections) to the live schema and returns the DDL AutoMigrate would run, without running it
+func Diff(ctx context.Context, db *gorm.DB, entities ...model.Entity) ([]string, error) {
+	recorder := &recorder{
+		ConnPool:  db.ConnPool,
+		dialector: db.Dialector,
+	}
+
+	dry := db.WithContext(ctx).Session(&gorm.Session{})
+	dry.Statement.ConnPool = recorder
+
+	for _, entity := range entities {
+		err := storage.AutoMigrate(dry, entity)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := storage.MigrateChildren(dry, entities...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return recorder.statements, nil
+}
+
+func (r *recorder) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	statement := r.dialector.Explain(query, args...)
+
+	// transactions of the migrator, ie when sqlite recreates a table
+	if !strings.Contains(strings.ToUpper(statement), "SAVEPOINT") {
+		r.statements = append(r.statements, statement)
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (r *recorder) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return r, nil
+}
+
+func (r *recorder) Commit() error {
+	return nil
+}
+
+func (r *recorder) Rollback() error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Meduzz/quickapi/storage"
+)
+
+type gadget struct {
+	ID    int64
+	Title string
+}
+
+func (gadget) Name() string     { return "gadgets" }
+func (gadget) Create() any      { return &gadget{} }
+func (gadget) CreateArray() any { return make([]*gadget, 0) }
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+
+	statements, err := Diff(ctx, db, gadget{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statements) != 1 || !strings.HasPrefix(statements[0], "CREATE TABLE `gadgets`") {
+		t.Fatalf("expected gadgets to be created, got %v", statements)
+	}
+
+	// the diff is not run
+	if db.Migrator().HasTable("gadgets") {
+		t.Fatal("expected no gadgets table")
+	}
+
+	err = storage.AutoMigrate(db, gadget{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err = Diff(ctx, db, gadget{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statements) != 0 {
+		t.Fatalf("expected no changes, got %v", statements)
+	}
+}
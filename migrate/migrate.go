@@ -0,0 +1,332 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"gorm.io/gorm"
+)
+
+type (
+	// Migration is a versioned change of the schema or data, applied in version order
+	Migration struct {
+		Version int64 // ie 1, 2, 3 or 20261019120000
+		Name    string
+		Up      func(tx *gorm.DB) error
+		Down    func(tx *gorm.DB) error // nil means it can't be rolled back
+	}
+
+	// Record is an applied migration, stored in the Table
+	Record struct {
+		Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+		Name      string
+		AppliedAt time.Time
+	}
+
+	// Status of a migration, AppliedAt is nil when it's pending.
+	// Migrations that are applied but not registered are Missing.
+	Status struct {
+		Version   int64      `json:"version"`
+		Name      string     `json:"name"`
+		AppliedAt *time.Time `json:"applied_at,omitempty"`
+		Missing   bool       `json:"missing,omitempty"`
+	}
+
+	// Migrator applies and rolls back migrations, tracked in the Table
+	Migrator struct {
+		db         *gorm.DB
+		migrations []*Migration
+	}
+)
+
+// Table keeps track of the applied migrations
+const Table = "quickapi_migrations"
+
+var (
+	registered     = make([]*Migration, 0)
+	registeredLock = sync.Mutex{}
+
+	// files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+	fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+)
+
+// Register makes the migrations available to Registered, ie from an init func
+func Register(migrations ...*Migration) {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+
+	registered = append(registered, migrations...)
+}
+
+// Registered returns the registered migrations
+func Registered() []*Migration {
+	registeredLock.Lock()
+	defer registeredLock.Unlock()
+
+	return append([]*Migration{}, registered...)
+}
+
+// SQL creates a migration from sql statements, down can be empty
+func SQL(version int64, name, up, down string) *Migration {
+	migration := &Migration{
+		Version: version,
+		Name:    name,
+		Up:      exec(up),
+	}
+
+	if down != "" {
+		migration.Down = exec(down)
+	}
+
+	return migration
+}
+
+// FS reads sql migrations from dir, named <version>_<name>.up.sql and <version>_<name>.down.sql
+func FS(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		bs, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if match[3] == "up" {
+			migration.Up = exec(string(bs))
+		} else {
+			migration.Down = exec(string(bs))
+		}
+	}
+
+	result := make([]*Migration, 0, len(byVersion))
+
+	for _, migration := range byVersion {
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+
+		result = append(result, migration)
+	}
+
+	sortByVersion(result)
+
+	return result, nil
+}
+
+// New creates a migrator for the migrations
+func New(db *gorm.DB, migrations ...*Migration) (*Migrator, error) {
+	sorted := append([]*Migration{}, migrations...)
+	sortByVersion(sorted)
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version %d", sorted[i-1].Name, sorted[i].Name, sorted[i].Version)
+		}
+	}
+
+	return &Migrator{db, sorted}, nil
+}
+
+// Up applies the pending migrations up to and including version to, 0 means all.
+// Each migration is applied in a transaction together with its record.
+func (m *Migrator) Up(ctx context.Context, to int64) ([]*Migration, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pending := slice.Filter(m.migrations, func(migration *Migration) bool {
+		_, ok := applied[migration.Version]
+		return !ok && (to == 0 || migration.Version <= to)
+	})
+
+	result := make([]*Migration, 0)
+
+	for _, migration := range pending {
+		err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := migration.Up(tx)
+
+			if err != nil {
+				return err
+			}
+
+			return tx.Table(Table).Create(&Record{migration.Version, migration.Name, time.Now()}).Error
+		})
+
+		if err != nil {
+			return result, fmt.Errorf("applying %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		result = append(result, migration)
+	}
+
+	return result, nil
+}
+
+// Down rolls back the latest steps applied migrations, in reverse version order
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
+
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, 0, len(applied))
+
+	for version := range applied {
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i] > versions[j]
+	})
+
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	result := make([]*Migration, 0)
+
+	for _, version := range versions {
+		migration := slice.Head(slice.Filter(m.migrations, func(it *Migration) bool {
+			return it.Version == version
+		}))
+
+		if migration == nil {
+			return result, fmt.Errorf("migration %d_%s is applied but not registered", version, applied[version].Name)
+		}
+
+		if migration.Down == nil {
+			return result, fmt.Errorf("migration %d_%s can't be rolled back", version, migration.Name)
+		}
+
+		err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := migration.Down(tx)
+
+			if err != nil {
+				return err
+			}
+
+			return tx.Table(Table).Delete(&Record{}, version).Error
+		})
+
+		if err != nil {
+			return result, fmt.Errorf("rolling back %d_%s: %w", version, migration.Name, err)
+		}
+
+		result = append(result, migration)
+	}
+
+	return result, nil
+}
+
+// Status lists the registered and applied migrations in version order
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := slice.Map(m.migrations, func(migration *Migration) *Status {
+		status := &Status{Version: migration.Version, Name: migration.Name}
+
+		if record, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+
+		return status
+	})
+
+	for _, record := range applied {
+		result = append(result, &Status{
+			Version:   record.Version,
+			Name:      record.Name,
+			AppliedAt: &record.AppliedAt,
+			Missing:   true,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// applied creates the Table if needed, and returns the applied migrations by version
+func (m *Migrator) applied(ctx context.Context) (map[int64]*Record, error) {
+	db := m.db.WithContext(ctx)
+	err := db.Table(Table).AutoMigrate(&Record{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*Record, 0)
+	err = db.Table(Table).Find(&records).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64]*Record)
+
+	for _, record := range records {
+		result[record.Version] = record
+	}
+
+	return result, nil
+}
+
+func exec(statements string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Exec(statements).Error
+	}
+}
+
+func sortByVersion(migrations []*Migration) {
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
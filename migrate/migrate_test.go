@@ -0,0 +1,287 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// database opens an empty in memory sqlite db
+func database(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one connection, every connection gets an in memory db of its own
+	pool, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetMaxOpenConns(1)
+
+	return db
+}
+
+func versions(migrations []*Migration) []int64 {
+	return slice.Map(migrations, func(migration *Migration) int64 {
+		return migration.Version
+	})
+}
+
+func equal(t *testing.T, expected, actual []int64) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("expected %v, got %v", expected, actual)
+	}
+
+	for i := range expected {
+		if expected[i] != actual[i] {
+			t.Fatalf("expected %v, got %v", expected, actual)
+		}
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+
+	// registered out of order
+	migrator, err := New(db,
+		SQL(3, "add_color", "ALTER TABLE widgets ADD COLUMN color TEXT", "ALTER TABLE widgets DROP COLUMN color"),
+		SQL(1, "create_widgets", "CREATE TABLE widgets (id INTEGER PRIMARY KEY)", "DROP TABLE widgets"),
+		SQL(2, "seed_widgets", "INSERT INTO widgets (id) VALUES (1)", "DELETE FROM widgets"),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equal(t, []int64{1, 2}, versions(applied))
+
+	status, err := migrator.Status(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 3 || status[1].AppliedAt == nil || status[2].AppliedAt != nil {
+		t.Fatalf("expected 1 and 2 applied and 3 pending, got %+v", status)
+	}
+
+	applied, err = migrator.Up(ctx, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equal(t, []int64{3}, versions(applied))
+
+	if !db.Migrator().HasColumn("widgets", "color") {
+		t.Fatal("expected widgets to have a color")
+	}
+
+	rolledBack, err := migrator.Down(ctx, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equal(t, []int64{3, 2}, versions(rolledBack))
+
+	count := int64(-1)
+	err = db.Table("widgets").Count(&count).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 0 || db.Migrator().HasColumn("widgets", "color") {
+		t.Fatalf("expected no widgets and no color, got %d widgets", count)
+	}
+
+	// everything is applied again
+	applied, err = migrator.Up(ctx, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equal(t, []int64{2, 3}, versions(applied))
+}
+
+func TestUpStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+
+	migrator, err := New(db,
+		SQL(1, "create_widgets", "CREATE TABLE widgets (id INTEGER PRIMARY KEY)", ""),
+		SQL(2, "broken", "CREATE TABLE gadgets (id INTEGER PRIMARY KEY); INSERT INTO nowhere VALUES (1)", ""),
+		SQL(3, "create_gizmos", "CREATE TABLE gizmos (id INTEGER PRIMARY KEY)", ""),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := migrator.Up(ctx, 0)
+
+	if err == nil || !strings.Contains(err.Error(), "2_broken") {
+		t.Fatalf("expected 2_broken to fail, got %v", err)
+	}
+
+	equal(t, []int64{1}, versions(applied))
+
+	// the failed migration is rolled back with its record
+	if db.Migrator().HasTable("gadgets") || db.Migrator().HasTable("gizmos") {
+		t.Fatal("expected no gadgets and no gizmos")
+	}
+
+	status, err := migrator.Status(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status[1].AppliedAt != nil {
+		t.Fatalf("expected 2 to be pending, got %v", status[1].AppliedAt)
+	}
+}
+
+func TestDownRefuses(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+	irreversible := SQL(1, "create_widgets", "CREATE TABLE widgets (id INTEGER PRIMARY KEY)", "")
+	removed := SQL(2, "create_gadgets", "CREATE TABLE gadgets (id INTEGER PRIMARY KEY)", "DROP TABLE gadgets")
+
+	migrator, err := New(db, irreversible, removed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up(ctx, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 is no longer registered
+	migrator, err = New(db, irreversible)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := migrator.Status(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(status) != 2 || !status[1].Missing {
+		t.Fatalf("expected 2 to be missing, got %+v", status)
+	}
+
+	_, err = migrator.Down(ctx, 1)
+
+	if err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Fatalf("expected 2 to be not registered, got %v", err)
+	}
+
+	migrator, err = New(db, irreversible, removed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rolledBack, err := migrator.Down(ctx, 2)
+
+	if err == nil || !strings.Contains(err.Error(), "can't be rolled back") {
+		t.Fatalf("expected 1 to be irreversible, got %v", err)
+	}
+
+	equal(t, []int64{2}, versions(rolledBack))
+}
+
+func TestDownRejectsSteps(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+
+	migrator, err := New(db, SQL(1, "create_widgets", "CREATE TABLE widgets (id INTEGER PRIMARY KEY)", "DROP TABLE widgets"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = migrator.Up(ctx, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, steps := range []int{0, -1} {
+		rolledBack, err := migrator.Down(ctx, steps)
+
+		if err == nil || len(rolledBack) > 0 {
+			t.Fatalf("expected %d steps to be an error, got %v", steps, versions(rolledBack))
+		}
+	}
+
+	if !db.Migrator().HasTable("widgets") {
+		t.Fatal("expected widgets to be left alone")
+	}
+}
+
+func TestNewRejectsDuplicateVersions(t *testing.T) {
+	_, err := New(database(t), SQL(1, "a", "SELECT 1", ""), SQL(1, "b", "SELECT 1", ""))
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/2_create_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY)")},
+		"sql/1_create_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY)")},
+		"sql/1_create_widgets.down.sql": {Data: []byte("DROP TABLE widgets")},
+		"sql/README.md":                 {Data: []byte("not a migration")},
+	}
+
+	migrations, err := FS(fsys, "sql")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equal(t, []int64{1, 2}, versions(migrations))
+
+	if migrations[0].Name != "create_widgets" || migrations[0].Down == nil || migrations[1].Down != nil {
+		t.Fatalf("expected create_widgets with a down and create_gadgets without, got %+v", migrations)
+	}
+
+	fsys["sql/3_drop_gadgets.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE gadgets")}
+	_, err = FS(fsys, "sql")
+
+	if err == nil || !strings.Contains(err.Error(), "no up file") {
+		t.Fatalf("expected 3 to have no up file, got %v", err)
+	}
+}
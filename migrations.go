@@ -0,0 +1,210 @@
+package quickapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/quickapi/migrate"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func migrateCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "migrate"
+	cmd.Short = "apply, roll back and inspect migrations, see migrate.Register"
+
+	cmd.AddCommand(migrateUpCommand(flags, db, entities))
+	cmd.AddCommand(migrateDownCommand(flags, db))
+	cmd.AddCommand(flags.printing(migrateStatusCommand(flags, db)))
+	cmd.AddCommand(migrateDiffCommand(flags, db, entities))
+
+	return cmd
+}
+
+func migrateUpCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "up"
+	cmd.Short = "apply the pending migrations, then auto migrate the entities"
+	cmd.Args = cobra.NoArgs
+
+	to := cmd.Flags().Int64("to", 0, "apply up to and including this version, 0 means all")
+	auto := cmd.Flags().Bool("auto", true, "auto migrate the entities after the migrations")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		db, err := flags.database(db)
+
+		if err != nil {
+			return err
+		}
+
+		migrator, err := migrate.New(db, migrate.Registered()...)
+
+		if err != nil {
+			return err
+		}
+
+		applied, err := migrator.Up(cmd.Context(), *to)
+		printMigrations(cmd.OutOrStdout(), "applied", applied)
+
+		if err != nil {
+			return err
+		}
+
+		if !*auto {
+			return nil
+		}
+
+		return Migrate(db, entities...)
+	}
+
+	return cmd
+}
+
+func migrateDownCommand(flags *dataFlags, db *gorm.DB) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "down"
+	cmd.Short = "roll back the latest applied migrations"
+	cmd.Args = cobra.NoArgs
+
+	steps := cmd.Flags().Int("steps", 1, "number of migrations to roll back")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if *steps < 1 {
+			return fmt.Errorf("--steps must be at least 1, got %d", *steps)
+		}
+
+		db, err := flags.database(db)
+
+		if err != nil {
+			return err
+		}
+
+		migrator, err := migrate.New(db, migrate.Registered()...)
+
+		if err != nil {
+			return err
+		}
+
+		rolledBack, err := migrator.Down(cmd.Context(), *steps)
+		printMigrations(cmd.OutOrStdout(), "rolled back", rolledBack)
+
+		return err
+	}
+
+	return cmd
+}
+
+func migrateStatusCommand(flags *dataFlags, db *gorm.DB) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "status"
+	cmd.Short = "list the migrations and when they were applied"
+	cmd.Args = cobra.NoArgs
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		db, err := flags.database(db)
+
+		if err != nil {
+			return err
+		}
+
+		migrator, err := migrate.New(db, migrate.Registered()...)
+
+		if err != nil {
+			return err
+		}
+
+		status, err := migrator.Status(cmd.Context())
+
+		if err != nil {
+			return err
+		}
+
+		if flags.output != "table" {
+			return flags.print(cmd.OutOrStdout(), nil, status)
+		}
+
+		rows := slice.Map(status, func(it *migrate.Status) map[string]any {
+			row := map[string]any{
+				"version": it.Version,
+				"name":    it.Name,
+				"applied": "pending",
+			}
+
+			if it.AppliedAt != nil {
+				row["applied"] = it.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			if it.Missing {
+				row["applied"] = fmt.Sprintf("%s (missing)", row["applied"])
+			}
+
+			return row
+		})
+
+		return table(cmd.OutOrStdout(), []string{"version", "name", "applied"}, rows)
+	}
+
+	return cmd
+}
+
+func migrateDiffCommand(flags *dataFlags, db *gorm.DB, entities []model.Entity) *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "diff"
+	cmd.Short = "print the DDL auto migrate would run for the entities, without running it"
+	cmd.Args = cobra.NoArgs
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		db, err := flags.database(db)
+
+		if err != nil {
+			return err
+		}
+
+		statements, err := migrate.Diff(cmd.Context(), db, entities...)
+
+		if err != nil {
+			return err
+		}
+
+		for _, statement := range statements {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s;\n", statement)
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// upgrade applies the registered migrations, then auto migrates the entities. Applied migrations are printed to out.
+func upgrade(ctx context.Context, out io.Writer, db *gorm.DB, entities []model.Entity) error {
+	migrator, err := migrate.New(db, migrate.Registered()...)
+
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx, 0)
+	printMigrations(out, "applied", applied)
+
+	if err != nil {
+		return err
+	}
+
+	return Migrate(db, entities...)
+}
+
+func printMigrations(out io.Writer, action string, migrations []*migrate.Migration) {
+	for _, migration := range migrations {
+		fmt.Fprintf(out, "%s %d %s\n", action, migration.Version, migration.Name)
+	}
+}
@@ -0,0 +1,60 @@
+package quickapi
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Meduzz/quickapi/migrate"
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func init() {
+	// registered once, like migrations are
+	migrate.Register(migrate.SQL(1, "create_widgets", "CREATE TABLE widgets (id INTEGER PRIMARY KEY)", "DROP TABLE widgets"))
+}
+
+func TestUpgradePrintsToOut(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	err = upgrade(context.Background(), out, db, []model.Entity{thing{}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "applied 1 create_widgets\n" {
+		t.Fatalf("expected the applied migration, got %q", out.String())
+	}
+
+	if !db.Migrator().HasTable("widgets") || !db.Migrator().HasTable(thing{}.Name()) {
+		t.Fatal("expected widgets and things to be migrated")
+	}
+}
+
+func TestMigrateDownRejectsSteps(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := migrateDownCommand(&dataFlags{}, db)
+	cmd.SetArgs([]string{"--steps", "-1"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err = cmd.Execute()
+
+	if err == nil || !strings.Contains(err.Error(), "--steps") {
+		t.Fatalf("expected --steps to be rejected, got %v", err)
+	}
+}
@@ -1,5 +1,7 @@
 package model
 
+import "slices"
+
 type (
 	PreloadConfig struct {
 		Condition string           // sql condition for the preload
@@ -8,6 +10,9 @@ type (
 
 	EntityKind string
 
+	// ChildMode is how update and patch save a child collection
+	ChildMode string
+
 	// Entity is the base of the api
 	Entity interface {
 		// Name will be used as a prefix in the path for the api
@@ -24,7 +29,99 @@ type (
 		Preload(string) map[string]*PreloadConfig
 	}
 
+	// PreloadDiscovery lists the preload aliases of PreloadSupport, so they can be documented
+	PreloadDiscovery interface {
+		Preloads() []string
+	}
+
+	// ExpandSupport lists the relations that can be expanded, by path of field or json names (ie pets.toys),
+	// entities without it can't be expanded
+	ExpandSupport interface {
+		Expands() []string
+	}
+
+	// SearchableSupport lists the fields (or columns) searched by full text search,
+	// fields can also be tagged with `quickapi:"searchable"`
+	SearchableSupport interface {
+		Searchable() []string
+	}
+
 	ScopeSupport interface {
 		Scopes() []*NamedFilter
 	}
+
+	// OperationSupport limits the operations exposed for the entity, entities without it expose all of them
+	OperationSupport interface {
+		Operations() []Operation
+	}
+
+	// KindSupport lets you pick how the entity is stored, entities without it are KindNormal
+	KindSupport interface {
+		Kind() EntityKind
+	}
+
+	// ChildrenSupport picks the mode of child collections (has one, has many & many to many) by relation (field) name,
+	// relations without a mode are ChildrenMerge
+	ChildrenSupport interface {
+		Children() map[string]ChildMode
+	}
+)
+
+const (
+	// KindNormal entities are stored as a table with a column per field
+	KindNormal EntityKind = "normal"
+	// KindJson entities are stored as a json document in a single column
+	KindJson EntityKind = "json"
+)
+
+const (
+	// ChildrenMerge creates and updates the children of an update, children missing from it are left as is
+	ChildrenMerge ChildMode = "merge"
+	// ChildrenReplace makes the children of an update or patch the only ones, children missing from it are deleted
+	// (many to many only loses the link)
+	ChildrenReplace ChildMode = "replace"
 )
+
+// ChildModeOf returns the mode of the relation of the entity
+func ChildModeOf(entity Entity, relation string) ChildMode {
+	childrenSupport, ok := entity.(ChildrenSupport)
+
+	if !ok {
+		return ChildrenMerge
+	}
+
+	mode, ok := childrenSupport.Children()[relation]
+
+	if !ok {
+		return ChildrenMerge
+	}
+
+	return mode
+}
+
+// KindOf returns the kind of the entity
+func KindOf(entity Entity) EntityKind {
+	kindSupport, ok := entity.(KindSupport)
+
+	if !ok {
+		return KindNormal
+	}
+
+	return kindSupport.Kind()
+}
+
+// OperationsOf returns the operations exposed for the entity
+func OperationsOf(entity Entity) []Operation {
+	operationSupport, ok := entity.(OperationSupport)
+
+	if !ok {
+		return Operations
+	}
+
+	return operationSupport.Operations()
+}
+
+// Enabled checks if the operation is exposed for the entity
+func Enabled(entity Entity, operation Operation) bool {
+	return slices.Contains(OperationsOf(entity), operation)
+}
@@ -0,0 +1,47 @@
+package model
+
+import "time"
+
+type (
+	// Operation is one of the operations quickapi exposes for an entity
+	Operation string
+
+	// Event describes a change made through the api
+	Event struct {
+		Entity    string    `json:"entity"`         // entity.Name
+		Operation Operation `json:"operation"`      // the operation that caused the change
+		ID        string    `json:"id,omitempty"`   // id from the request, empty on create
+		Data      any       `json:"data,omitempty"` // the entity after the change, empty on delete
+		Timestamp time.Time `json:"timestamp"`      // when the change was made
+	}
+)
+
+const (
+	OperationCreate Operation = "create"
+	OperationRead   Operation = "read"
+	OperationUpdate Operation = "update"
+	OperationDelete Operation = "delete"
+	OperationSearch Operation = "search"
+	OperationPatch  Operation = "patch"
+)
+
+// Operations are all operations, in the order they're routed
+var Operations = []Operation{
+	OperationCreate,
+	OperationRead,
+	OperationUpdate,
+	OperationDelete,
+	OperationSearch,
+	OperationPatch,
+}
+
+// NewEvent creates a new event for the named entity.
+func NewEvent(entity string, operation Operation, id string, data any) *Event {
+	return &Event{
+		Entity:    entity,
+		Operation: operation,
+		ID:        id,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+}
@@ -0,0 +1,483 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type (
+	// IDStrategy is how the primary key of an entity is created, and parsed from paths
+	IDStrategy string
+
+	// KeySupport declares the primary key column (field or column name) and ID strategy of the entity.
+	// Entities without it use the gorm primary key (ID unless another field is tagged primaryKey),
+	// IDAuto for integer keys and IDNatural for the rest.
+	KeySupport interface {
+		Key() (string, IDStrategy)
+	}
+
+	// CompositeKeySupport declares the fields (or columns) of a composite primary key, in the order of the id.
+	// Each field must be tagged with gorm:"primaryKey", entities without it use all their primary keys in field order.
+	CompositeKeySupport interface {
+		Keys() []string
+	}
+
+	// Key is the primary key of an entity
+	Key struct {
+		Fields   []*schema.Field // empty for json entities, their key is an auto increment id
+		Strategy IDStrategy      // IDNatural for composite keys
+		row      []int           // index of the field a json entity keeps its row id in, if any
+	}
+)
+
+const (
+	// IDAuto keys are integers picked by the db
+	IDAuto IDStrategy = "auto"
+	// IDUUIDv4 keys are random uuids, generated on create unless given
+	IDUUIDv4 IDStrategy = "uuidv4"
+	// IDUUIDv7 keys are time ordered uuids, generated on create unless given
+	IDUUIDv7 IDStrategy = "uuidv7"
+	// IDULID keys are time ordered ulids, generated on create unless given
+	IDULID IDStrategy = "ulid"
+	// IDNatural keys are given by the client
+	IDNatural IDStrategy = "natural"
+)
+
+const (
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// separator joins the values of composite ids, ie /memberships/1,2
+	separator = ","
+)
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	ulidPattern = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+// KeyOf returns the primary key of the entity, and checks that the strategy fits the type of the key
+func KeyOf(entity Entity) (*Key, error) {
+	if KindOf(entity) == KindJson {
+		return jsonKey(entity), nil
+	}
+
+	sch, err := Schema(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return declaredKey(sch, entity, entity.Name())
+}
+
+// KeyOfSchema returns the primary key of a gorm struct that is not an entity itself, ie a child in a relation.
+// Like KeyOf it honours KeySupport and CompositeKeySupport.
+func KeyOfSchema(sch *schema.Schema) (*Key, error) {
+	return declaredKey(sch, reflect.New(sch.ModelType).Interface(), sch.Name)
+}
+
+// declaredKey is the key of sch, as declared by it (the entity or a *T) or its default key
+func declaredKey(sch *schema.Schema, it any, entity string) (*Key, error) {
+	lookup := func(name string) (*schema.Field, error) {
+		field := sch.LookUpField(name)
+
+		if field == nil || !slices.Contains(sch.PrimaryFields, field) {
+			return nil, fmt.Errorf("%s is not a primary key of %s, tag it with gorm:\"primaryKey\"", name, entity)
+		}
+
+		return field, nil
+	}
+
+	if compositeKeySupport, ok := it.(CompositeKeySupport); ok {
+		fields := make([]*schema.Field, 0)
+
+		for _, name := range compositeKeySupport.Keys() {
+			field, err := lookup(name)
+
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, field)
+		}
+
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%s declares no keys", entity)
+		}
+
+		return &Key{Fields: fields, Strategy: IDNatural}, nil
+	}
+
+	keySupport, ok := it.(KeySupport)
+
+	if !ok {
+		return DefaultKey(sch)
+	}
+
+	name, strategy := keySupport.Key()
+	field, err := lookup(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{Fields: []*schema.Field{field}, Strategy: strategy}
+
+	return key, key.check()
+}
+
+// jsonKey is the id of the row a json entity is stored in, kept in its ID field (or the one of KeySupport) when it's an integer.
+// Documents are not parsed by gorm, their nested structs would be taken for relations.
+func jsonKey(entity Entity) *Key {
+	name := "ID"
+
+	if keySupport, ok := entity.(KeySupport); ok {
+		name, _ = keySupport.Key()
+	}
+
+	t := reflect.TypeOf(entity.Create())
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return &Key{Strategy: IDAuto}
+	}
+
+	field, ok := t.FieldByName(name)
+
+	// Int through Uint64 are the integer kinds
+	if !ok || !field.IsExported() || field.Type.Kind() < reflect.Int || field.Type.Kind() > reflect.Uint64 {
+		return &Key{Strategy: IDAuto}
+	}
+
+	return &Key{Strategy: IDAuto, row: field.Index}
+}
+
+// DefaultKey returns the primary key of sch, IDAuto for integers and IDNatural for the rest.
+// Several primary keys make a composite (natural) key.
+func DefaultKey(sch *schema.Schema) (*Key, error) {
+	if len(sch.PrimaryFields) == 0 {
+		return nil, fmt.Errorf("%s has no primary key", sch.Name)
+	}
+
+	if len(sch.PrimaryFields) == 1 && isInteger(sch.PrimaryFields[0]) {
+		return &Key{Fields: sch.PrimaryFields, Strategy: IDAuto}, nil
+	}
+
+	return &Key{Fields: sch.PrimaryFields, Strategy: IDNatural}, nil
+}
+
+// Columns are the columns of the key, in the order of the id
+func (k *Key) Columns() []string {
+	if len(k.Fields) == 0 {
+		return []string{"id"}
+	}
+
+	return slice.Map(k.Fields, func(field *schema.Field) string {
+		return field.DBName
+	})
+}
+
+// Composite is true for keys of several columns
+func (k *Key) Composite() bool {
+	return len(k.Fields) > 1
+}
+
+// Parse validates an id (ie from a path) by the strategy, and converts it to the type of the key.
+// Composite ids are the values joined by commas (only the last may contain commas), and parse into an []any.
+func (k *Key) Parse(id string) (any, error) {
+	if k.Composite() {
+		parts := strings.SplitN(id, separator, len(k.Fields))
+
+		if len(parts) != len(k.Fields) {
+			return nil, fmt.Errorf("%s is not %d values separated by %s", id, len(k.Fields), separator)
+		}
+
+		values := make([]any, 0, len(parts))
+
+		for i, part := range parts {
+			value, err := natural(k.Fields[i], part)
+
+			if err != nil {
+				return nil, err
+			}
+
+			values = append(values, value)
+		}
+
+		return values, nil
+	}
+
+	switch k.Strategy {
+	case IDAuto:
+		return k.integer(id)
+	case IDUUIDv4, IDUUIDv7:
+		id = strings.ToLower(id)
+		version := map[IDStrategy]byte{IDUUIDv4: '4', IDUUIDv7: '7'}[k.Strategy]
+
+		if !uuidPattern.MatchString(id) || id[14] != version {
+			return nil, fmt.Errorf("%s is not a %s", id, k.Strategy)
+		}
+
+		return id, nil
+	case IDULID:
+		id = strings.ToUpper(id)
+
+		if !ulidPattern.MatchString(id) {
+			return nil, fmt.Errorf("%s is not a ulid", id)
+		}
+
+		return id, nil
+	default:
+		if len(k.Fields) == 0 {
+			return k.integer(id)
+		}
+
+		return natural(k.Fields[0], id)
+	}
+}
+
+// Format is the id of a parsed (or read) value, the inverse of Parse
+func (k *Key) Format(value any) string {
+	if values, ok := value.([]any); ok {
+		return strings.Join(slice.Map(values, func(value any) string {
+			return fmt.Sprint(value)
+		}), separator)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Where is the condition on the key columns in table, for a parsed id
+func (k *Key) Where(table string, value any) clause.Expression {
+	values, ok := value.([]any)
+
+	if !ok {
+		values = []any{value}
+	}
+
+	exprs := make([]clause.Expression, 0, len(values))
+
+	for i, column := range k.Columns() {
+		exprs = append(exprs, clause.Eq{Column: clause.Column{Table: table, Name: column}, Value: values[i]})
+	}
+
+	return clause.And(exprs...)
+}
+
+// ValueOf reads the key of entity (a *T), an []any for composite keys. Zero when any part is zero.
+func (k *Key) ValueOf(ctx context.Context, entity any) (any, bool) {
+	reflected := reflect.ValueOf(entity)
+
+	if k.row != nil {
+		field, err := reflect.Indirect(reflected).FieldByIndexErr(k.row)
+
+		if err != nil {
+			return nil, true
+		}
+
+		return field.Interface(), field.IsZero()
+	}
+	values := make([]any, 0, len(k.Fields))
+	zero := false
+
+	for _, field := range k.Fields {
+		value, isZero := field.ValueOf(ctx, reflected)
+		values = append(values, value)
+		zero = zero || isZero
+	}
+
+	if len(values) == 1 {
+		return values[0], zero
+	}
+
+	return values, zero
+}
+
+// Set sets the key of entity (a *T) to a parsed id
+func (k *Key) Set(ctx context.Context, entity any, value any) error {
+	values, ok := value.([]any)
+
+	if !ok {
+		values = []any{value}
+	}
+
+	reflected := reflect.ValueOf(entity)
+
+	if k.row != nil {
+		return k.setRow(reflected, value)
+	}
+
+	for i, field := range k.Fields {
+		err := field.Set(ctx, reflected, values[i])
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setRow sets the row id of a json entity on its key field
+func (k *Key) setRow(entity reflect.Value, value any) error {
+	field, err := reflect.Indirect(entity).FieldByIndexErr(k.row)
+
+	if err != nil {
+		return err
+	}
+
+	id := reflect.ValueOf(value)
+
+	if !id.CanConvert(field.Type()) {
+		return fmt.Errorf("%v can't be set on a %s", value, field.Type())
+	}
+
+	field.Set(id.Convert(field.Type()))
+
+	return nil
+}
+
+// Generate creates a new id by the strategy, false when the db or the client picks it
+func (k *Key) Generate() (string, bool) {
+	switch k.Strategy {
+	case IDUUIDv4:
+		b := random(0)
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+
+		return uuid(b), true
+	case IDUUIDv7:
+		b := random(time.Now().UnixMilli())
+		b[6] = b[6]&0x0f | 0x70
+		b[8] = b[8]&0x3f | 0x80
+
+		return uuid(b), true
+	case IDULID:
+		b := random(time.Now().UnixMilli())
+		hi := binary.BigEndian.Uint64(b[:8])
+		lo := binary.BigEndian.Uint64(b[8:])
+		out := make([]byte, 26)
+
+		// 5 bits at a time from the end, the first character gets the 3 top bits
+		for i := len(out) - 1; i >= 0; i-- {
+			out[i] = crockford[lo&31]
+			lo = lo>>5 | hi<<59
+			hi >>= 5
+		}
+
+		return string(out), true
+	default:
+		return "", false
+	}
+}
+
+// Assign generates the key of entity (a *T) when it's zero, natural keys (each part of composite keys) are required
+func (k *Key) Assign(ctx context.Context, entity any) error {
+	if len(k.Fields) == 0 || k.Strategy == IDAuto {
+		return nil
+	}
+
+	value := reflect.ValueOf(entity)
+
+	for _, field := range k.Fields {
+		if _, zero := field.ValueOf(ctx, value); !zero {
+			continue
+		}
+
+		id, ok := k.Generate()
+
+		if !ok {
+			return fmt.Errorf("%s is required", field.Name)
+		}
+
+		err := field.Set(ctx, value, id)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *Key) check() error {
+	field := k.Fields[0]
+
+	switch k.Strategy {
+	case IDAuto:
+		if !isInteger(field) {
+			return fmt.Errorf("%s is not an integer, required by %s", field.Name, k.Strategy)
+		}
+	case IDUUIDv4, IDUUIDv7, IDULID:
+		if field.DataType != schema.String {
+			return fmt.Errorf("%s is not a string, required by %s", field.Name, k.Strategy)
+		}
+	case IDNatural:
+	default:
+		return fmt.Errorf("unknown id strategy %s", k.Strategy)
+	}
+
+	return nil
+}
+
+func (k *Key) integer(id string) (any, error) {
+	if len(k.Fields) > 0 && k.Fields[0].DataType == schema.Uint {
+		return strconv.ParseUint(id, 10, 64)
+	}
+
+	return strconv.ParseInt(id, 10, 64)
+}
+
+// natural parses a value given by the client, integers for integer fields
+func natural(field *schema.Field, id string) (any, error) {
+	if id == "" {
+		return nil, fmt.Errorf("empty %s", field.Name)
+	}
+
+	if field.DataType == schema.Uint {
+		return strconv.ParseUint(id, 10, 64)
+	}
+
+	if field.DataType == schema.Int {
+		return strconv.ParseInt(id, 10, 64)
+	}
+
+	return id, nil
+}
+
+// random returns 16 random bytes, starting with the 48 bit unix milli when given
+func random(milli int64) []byte {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	if milli > 0 {
+		binary.BigEndian.PutUint16(b[0:2], uint16(milli>>32))
+		binary.BigEndian.PutUint32(b[2:6], uint32(milli))
+	}
+
+	return b
+}
+
+func uuid(b []byte) string {
+	s := hex.EncodeToString(b)
+	return strings.Join([]string{s[0:8], s[8:12], s[12:16], s[16:20], s[20:32]}, "-")
+}
+
+func isInteger(field *schema.Field) bool {
+	return field.DataType == schema.Int || field.DataType == schema.Uint
+}
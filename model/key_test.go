@@ -0,0 +1,367 @@
+package model
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+type (
+	autoInt struct {
+		ID int64
+	}
+
+	autoUint struct {
+		ID uint
+	}
+
+	// keyed has a string key, generated by its strategy
+	keyed struct {
+		Code     string `gorm:"primaryKey"`
+		strategy IDStrategy
+	}
+)
+
+func (autoInt) Name() string              { return "ints" }
+func (autoInt) Create() any               { return &autoInt{} }
+func (autoInt) CreateArray() any          { return make([]*autoInt, 0) }
+func (autoUint) Name() string             { return "uints" }
+func (autoUint) Create() any              { return &autoUint{} }
+func (autoUint) CreateArray() any         { return make([]*autoUint, 0) }
+func (keyed) Name() string                { return "keyed" }
+func (keyed) Create() any                 { return &keyed{} }
+func (keyed) CreateArray() any            { return make([]*keyed, 0) }
+func (k keyed) Key() (string, IDStrategy) { return "Code", k.strategy }
+
+func keyOf(t *testing.T, entity Entity) *Key {
+	t.Helper()
+
+	key, err := KeyOf(entity)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestKeyParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity Entity
+		id     string
+		want   any // nil when the id is invalid
+	}{
+		{"int", autoInt{}, "42", int64(42)},
+		{"negative int", autoInt{}, "-1", int64(-1)},
+		{"not an int", autoInt{}, "x", nil},
+		{"uint", autoUint{}, "42", uint64(42)},
+		{"negative uint", autoUint{}, "-1", nil},
+		{"uuidv4", keyed{strategy: IDUUIDv4}, "0b9e3a6c-1f2d-4e5a-8b7c-9d0e1f2a3b4c", "0b9e3a6c-1f2d-4e5a-8b7c-9d0e1f2a3b4c"},
+		{"uuidv4 upper case", keyed{strategy: IDUUIDv4}, "0B9E3A6C-1F2D-4E5A-8B7C-9D0E1F2A3B4C", "0b9e3a6c-1f2d-4e5a-8b7c-9d0e1f2a3b4c"},
+		{"uuidv7 as uuidv4", keyed{strategy: IDUUIDv4}, "0190a1b2-c3d4-7e5f-8a6b-7c8d9e0f1a2b", nil},
+		{"uuidv7", keyed{strategy: IDUUIDv7}, "0190a1b2-c3d4-7e5f-8a6b-7c8d9e0f1a2b", "0190a1b2-c3d4-7e5f-8a6b-7c8d9e0f1a2b"},
+		{"uuidv4 as uuidv7", keyed{strategy: IDUUIDv7}, "0b9e3a6c-1f2d-4e5a-8b7c-9d0e1f2a3b4c", nil},
+		{"not a uuid", keyed{strategy: IDUUIDv4}, "0b9e3a6c1f2d4e5a8b7c9d0e1f2a3b4c", nil},
+		{"ulid", keyed{strategy: IDULID}, "01ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+		{"ulid lower case", keyed{strategy: IDULID}, "01arz3ndektsv4rrffq69g5fav", "01ARZ3NDEKTSV4RRFFQ69G5FAV"},
+		{"ulid overflowing 128 bits", keyed{strategy: IDULID}, "81ARZ3NDEKTSV4RRFFQ69G5FAV", nil},
+		{"ulid outside the alphabet", keyed{strategy: IDULID}, "01ARZ3NDEKTSV4RRFFQ69G5FAU", nil},
+		{"ulid too short", keyed{strategy: IDULID}, "01ARZ3NDEKTSV4RRFFQ69G5FA", nil},
+		{"natural", keyed{strategy: IDNatural}, "abc", "abc"},
+		{"empty natural", keyed{strategy: IDNatural}, "", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := keyOf(t, test.entity)
+			value, err := key.Parse(test.id)
+
+			if test.want == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", value)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if value != test.want {
+				t.Fatalf("expected %v (%T), got %v (%T)", test.want, test.want, value, value)
+			}
+
+			if formatted := key.Format(value); formatted != key.Format(test.want) {
+				t.Fatalf("expected %v to format as %v, got %s", value, test.want, formatted)
+			}
+		})
+	}
+}
+
+func TestKeyGenerate(t *testing.T) {
+	tests := []struct {
+		strategy IDStrategy
+		valid    func(string) bool
+	}{
+		{IDUUIDv4, func(id string) bool { return id[14] == '4' && strings.ContainsRune("89ab", rune(id[19])) }},
+		{IDUUIDv7, func(id string) bool { return id[14] == '7' && strings.ContainsRune("89ab", rune(id[19])) }},
+		{IDULID, func(id string) bool {
+			return strings.ContainsRune("01234567", rune(id[0])) && strings.Trim(id, crockford) == ""
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.strategy), func(t *testing.T) {
+			key := keyOf(t, keyed{strategy: test.strategy})
+
+			for range 100 {
+				id, ok := key.Generate()
+
+				if !ok {
+					t.Fatal("expected an id")
+				}
+
+				if !test.valid(id) {
+					t.Fatalf("%s is not a valid %s", id, test.strategy)
+				}
+
+				_, err := key.Parse(id)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyGenerateIsTimeOrdered(t *testing.T) {
+	for _, strategy := range []IDStrategy{IDUUIDv7, IDULID} {
+		key := keyOf(t, keyed{strategy: strategy})
+		first, _ := key.Generate()
+		time.Sleep(2 * time.Millisecond)
+		second, _ := key.Generate()
+
+		if first >= second {
+			t.Errorf("%s: expected %s before %s", strategy, first, second)
+		}
+	}
+}
+
+func TestKeyGenerateLeavesOthers(t *testing.T) {
+	for _, entity := range []Entity{autoInt{}, keyed{strategy: IDNatural}} {
+		_, ok := keyOf(t, entity).Generate()
+
+		if ok {
+			t.Errorf("%s: expected no generated id", entity.Name())
+		}
+	}
+}
+
+func TestKeyOfChecksStrategy(t *testing.T) {
+	tests := []struct {
+		name   string
+		entity Entity
+		want   IDStrategy // empty when the strategy doesn't fit
+		column string
+	}{
+		{"int", autoInt{}, IDAuto, "id"},
+		{"uint", autoUint{}, IDAuto, "id"},
+		{"string", keyed{strategy: IDULID}, IDULID, "code"},
+		{"auto on string", keyed{strategy: IDAuto}, "", ""},
+		{"unknown", keyed{strategy: "serial"}, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := KeyOf(test.entity)
+
+			if test.want == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", key.Strategy)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if key.Strategy != test.want || !reflect.DeepEqual(key.Columns(), []string{test.column}) {
+				t.Fatalf("expected %s, got %s on %v", test.want, key.Strategy, key.Columns())
+			}
+		})
+	}
+}
+
+type (
+	membership struct {
+		OrgID  int64  `gorm:"primaryKey"`
+		UserID uint   `gorm:"primaryKey"`
+		Path   string `gorm:"primaryKey"`
+	}
+
+	// ordered declares its key in another order than its fields
+	ordered struct {
+		OrgID  int64 `gorm:"primaryKey"`
+		UserID int64 `gorm:"primaryKey"`
+	}
+)
+
+func (membership) Name() string     { return "memberships" }
+func (membership) Create() any      { return &membership{} }
+func (membership) CreateArray() any { return make([]*membership, 0) }
+func (ordered) Name() string        { return "ordered" }
+func (ordered) Create() any         { return &ordered{} }
+func (ordered) CreateArray() any    { return make([]*ordered, 0) }
+func (ordered) Keys() []string      { return []string{"UserID", "OrgID"} }
+
+func TestCompositeKeyParse(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want []any // nil when the id is invalid
+	}{
+		{"values", "1,2,a", []any{int64(1), uint64(2), "a"}},
+		{"commas in the last part", "1,2,a,b,c", []any{int64(1), uint64(2), "a,b,c"}},
+		{"negative int", "-1,2,a", []any{int64(-1), uint64(2), "a"}},
+		{"negative uint", "1,-2,a", nil},
+		{"commas in an int part", "1,2", nil},
+		{"empty part", "1,2,", nil},
+		{"not an int", "x,2,a", nil},
+	}
+
+	key := keyOf(t, membership{})
+
+	if !key.Composite() || key.Strategy != IDNatural {
+		t.Fatalf("expected a composite natural key, got %v", key.Columns())
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := key.Parse(test.id)
+
+			if test.want == nil {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", value)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(value, test.want) {
+				t.Fatalf("expected %#v, got %#v", test.want, value)
+			}
+
+			if formatted := key.Format(value); formatted != test.id {
+				t.Fatalf("expected %s, got %s", test.id, formatted)
+			}
+		})
+	}
+}
+
+func TestCompositeKeyOrder(t *testing.T) {
+	key := keyOf(t, ordered{})
+
+	if !reflect.DeepEqual(key.Columns(), []string{"user_id", "org_id"}) {
+		t.Fatalf("expected the declared order, got %v", key.Columns())
+	}
+
+	value, err := key.Parse("2,1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entity := &ordered{}
+	err = key.Set(context.Background(), entity, value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entity.UserID != 2 || entity.OrgID != 1 {
+		t.Fatalf("expected user 2 of org 1, got %+v", entity)
+	}
+
+	read, zero := key.ValueOf(context.Background(), entity)
+
+	if zero || key.Format(read) != "2,1" {
+		t.Fatalf("expected 2,1, got %v", read)
+	}
+
+	_, zero = key.ValueOf(context.Background(), &ordered{UserID: 2})
+
+	if !zero {
+		t.Fatal("expected a zero key when a part is zero")
+	}
+}
+
+func TestCompositeKeyWhere(t *testing.T) {
+	key := keyOf(t, ordered{})
+	where, ok := key.Where("ordered", []any{int64(2), int64(1)}).(clause.AndConditions)
+
+	if !ok {
+		t.Fatalf("expected and conditions, got %T", where)
+	}
+
+	expected := []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: "ordered", Name: "user_id"}, Value: int64(2)},
+		clause.Eq{Column: clause.Column{Table: "ordered", Name: "org_id"}, Value: int64(1)},
+	}
+
+	if !reflect.DeepEqual(where.Exprs, expected) {
+		t.Fatalf("expected %v, got %v", expected, where.Exprs)
+	}
+}
+
+type (
+	// document is stored as json, gorm can't parse it since it takes Owner for a relation
+	document struct {
+		ID    uint      `json:"key"`
+		Owner *document `json:"owner"`
+	}
+)
+
+func (document) Name() string     { return "documents" }
+func (document) Create() any      { return &document{} }
+func (document) CreateArray() any { return make([]*document, 0) }
+func (document) Kind() EntityKind { return KindJson }
+
+func TestJsonKey(t *testing.T) {
+	key := keyOf(t, document{})
+
+	if key.Strategy != IDAuto || !reflect.DeepEqual(key.Columns(), []string{"id"}) {
+		t.Fatalf("expected an auto id, got %s on %v", key.Strategy, key.Columns())
+	}
+
+	value, err := key.Parse("7")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entity := &document{}
+	err = key.Set(context.Background(), entity, value)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, zero := key.ValueOf(context.Background(), entity)
+
+	if entity.ID != 7 || zero || key.Format(read) != "7" {
+		t.Fatalf("expected 7, got %v", read)
+	}
+}
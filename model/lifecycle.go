@@ -0,0 +1,88 @@
+package model
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Meduzz/helper/http/herror"
+)
+
+type (
+	// Context is handed to the lifecycle hooks of an entity. The same
+	// Context is used for the before and after hook of a request, so
+	// Metadata can be used to pass data between them.
+	Context struct {
+		context.Context
+		Entity    string         // entity.Name
+		Operation Operation      // the operation being executed
+		Principal any            // whoever made the request, if known
+		Request   *http.Request  // the http request
+		Metadata  map[string]any // free for the hooks to use
+	}
+
+	// BeforeCreateSupport is called with the bound body before it's created, the body can be modified.
+	BeforeCreateSupport interface {
+		OnBeforeCreate(*Context, any) error
+	}
+
+	// AfterCreateSupport is called with the created entity, errors are logged since the entity is already saved.
+	AfterCreateSupport interface {
+		OnAfterCreate(*Context, any) error
+	}
+
+	// BeforeReadSupport is called with the id before it's read.
+	BeforeReadSupport interface {
+		OnBeforeRead(*Context, string) error
+	}
+
+	// AfterReadSupport is called with the entity that was read, the entity can be modified.
+	AfterReadSupport interface {
+		OnAfterRead(*Context, any) error
+	}
+
+	// BeforeUpdateSupport is called with the id and bound body before it's updated, the body can be modified.
+	BeforeUpdateSupport interface {
+		OnBeforeUpdate(*Context, string, any) error
+	}
+
+	// AfterUpdateSupport is called with the updated entity, errors are logged since the entity is already saved.
+	AfterUpdateSupport interface {
+		OnAfterUpdate(*Context, any) error
+	}
+
+	// BeforeDeleteSupport is called with the id before it's deleted.
+	BeforeDeleteSupport interface {
+		OnBeforeDelete(*Context, string) error
+	}
+
+	// AfterDeleteSupport is called with the id of the deleted entity, errors are logged since the entity is already deleted.
+	AfterDeleteSupport interface {
+		OnAfterDelete(*Context, string) error
+	}
+
+	// BeforeSearchSupport is called with the where filters before searching, the filters can be modified.
+	BeforeSearchSupport interface {
+		OnBeforeSearch(*Context, map[string]string) error
+	}
+
+	// AfterSearchSupport is called with the search result (CreateArray), the result can be modified.
+	AfterSearchSupport interface {
+		OnAfterSearch(*Context, any) error
+	}
+
+	// BeforePatchSupport is called with the id and patch data before it's patched, the data can be modified.
+	BeforePatchSupport interface {
+		OnBeforePatch(*Context, string, map[string]any) error
+	}
+
+	// AfterPatchSupport is called with the patched entity, errors are logged since the entity is already saved.
+	AfterPatchSupport interface {
+		OnAfterPatch(*Context, any) error
+	}
+)
+
+// Veto creates an error that a hook can return to stop an operation,
+// code will be used as the http status of the response, and reason is in its body.
+func Veto(code int, reason string) error {
+	return herror.NewHttpError(code, reason)
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+var schemas = &sync.Map{}
+
+// Schema parses (and caches) the gorm schema of the entity, with gorms default naming.
+// It's used to find columns, keys and relations of an entity without a db connection.
+func Schema(entity Entity) (*schema.Schema, error) {
+	return schema.Parse(entity.Create(), schemas, schema.NamingStrategy{})
+}
+
+// SearchableOf returns the columns searched by full text search, from SearchableSupport and tagged fields
+func SearchableOf(entity Entity) ([]string, error) {
+	if KindOf(entity) != KindNormal {
+		return nil, nil
+	}
+
+	sch, err := Schema(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]string, 0)
+
+	if searchableSupport, ok := entity.(SearchableSupport); ok {
+		for _, name := range searchableSupport.Searchable() {
+			field := sch.LookUpField(name)
+
+			if field == nil || field.DBName == "" {
+				return nil, fmt.Errorf("unknown searchable column %s in %s", name, entity.Name())
+			}
+
+			columns = append(columns, field.DBName)
+		}
+	}
+
+	for _, field := range sch.Fields {
+		tags := strings.Split(field.Tag.Get("quickapi"), ",")
+
+		if field.DBName != "" && slices.Contains(tags, "searchable") && !slices.Contains(columns, field.DBName) {
+			columns = append(columns, field.DBName)
+		}
+	}
+
+	return columns, nil
+}
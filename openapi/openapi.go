@@ -0,0 +1,508 @@
+package openapi
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/Meduzz/quickapi/schema"
+	gormschema "gorm.io/gorm/schema"
+)
+
+type (
+	Document struct {
+		OpenAPI    string               `json:"openapi"`
+		Info       *Info                `json:"info"`
+		Paths      map[string]*PathItem `json:"paths"`
+		Components *Components          `json:"components,omitempty"`
+	}
+
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	PathItem struct {
+		Get    *Operation `json:"get,omitempty"`
+		Put    *Operation `json:"put,omitempty"`
+		Post   *Operation `json:"post,omitempty"`
+		Delete *Operation `json:"delete,omitempty"`
+		Patch  *Operation `json:"patch,omitempty"`
+	}
+
+	Operation struct {
+		OperationID string               `json:"operationId"`
+		Summary     string               `json:"summary,omitempty"`
+		Tags        []string             `json:"tags,omitempty"`
+		Parameters  []*Parameter         `json:"parameters,omitempty"`
+		RequestBody *RequestBody         `json:"requestBody,omitempty"`
+		Responses   map[string]*Response `json:"responses"`
+	}
+
+	Parameter struct {
+		Name        string         `json:"name"`
+		In          string         `json:"in"`
+		Description string         `json:"description,omitempty"`
+		Required    bool           `json:"required,omitempty"`
+		Style       string         `json:"style,omitempty"`
+		Explode     *bool          `json:"explode,omitempty"`
+		Schema      *schema.Schema `json:"schema"`
+	}
+
+	RequestBody struct {
+		Required bool                  `json:"required,omitempty"`
+		Content  map[string]*MediaType `json:"content"`
+	}
+
+	MediaType struct {
+		Schema *schema.Schema `json:"schema"`
+	}
+
+	Response struct {
+		Description string                `json:"description"`
+		Content     map[string]*MediaType `json:"content,omitempty"`
+	}
+
+	Components struct {
+		Schemas map[string]*schema.Schema `json:"schemas,omitempty"`
+	}
+
+	// Params are the names of the parameters quickapi reads, see http.DefaultConfig
+	Params struct {
+		ID      string
+		Where   string
+		Sort    string
+		Preload string
+		Expand  string
+		GroupBy string
+		Query   string
+		Field   string
+		Skip    string
+		Take    string
+	}
+)
+
+const (
+	Version = "3.1.0"
+	JSON    = "application/json"
+)
+
+// DefaultParams are the names of the parameters read with the default config of the http package.
+// They live here, so that clients can use them without depending on the server.
+func DefaultParams() *Params {
+	return &Params{
+		ID:      "id",
+		Where:   "where",
+		Sort:    "sort",
+		Preload: "preload",
+		Expand:  "expand",
+		GroupBy: "group_by",
+		Query:   "q",
+		Field:   "field",
+		Skip:    "skip",
+		Take:    "take",
+	}
+}
+
+// Build creates an OpenAPI document covering the routed operations of the entities, served under basePath.
+// Nested tells which entities have routes for their children (see http.For), nil when none has.
+func Build(info *Info, basePath string, params *Params, nested func(model.Entity) bool, entities ...model.Entity) *Document {
+	builder := schema.NewBuilder("#/components/schemas/")
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+
+	for _, entity := range entities {
+		name := entity.Name()
+		it := builder.Entity(entity)
+		list := &schema.Schema{Type: "array", Items: it}
+		id := &Parameter{Name: params.ID, In: "path", Required: true, Schema: idSchema(entity)}
+		preloads := preloadParameter(entity, params)
+		expand := expandParameters(entity, params)
+		query := queryParameters(entity, params)
+		filters := filterParameters(entity)
+
+		doc.add(path.Join("/", basePath, name)+"/", &PathItem{
+			Post: enabled(entity, model.OperationCreate, &Operation{
+				OperationID: fmt.Sprintf("create_%s", name),
+				Summary:     fmt.Sprintf("Create a new %s", name),
+				Tags:        []string{name},
+				RequestBody: body(it),
+				Responses:   responses("201", it, "400", "409"),
+			}),
+			Get: enabled(entity, model.OperationSearch, &Operation{
+				OperationID: fmt.Sprintf("search_%s", name),
+				Summary:     fmt.Sprintf("Search %s", name),
+				Tags:        []string{name},
+				Parameters: slice.Concat([]*Parameter{
+					{Name: params.Skip, In: "query", Schema: &schema.Schema{Type: "integer"}},
+					{Name: params.Take, In: "query", Schema: &schema.Schema{Type: "integer"}},
+					deepObject(params.Where, "equality filters by column", columns(entity, builder)),
+					deepObject(params.Sort, "sort by column, asc or desc", sortColumns(entity)),
+					preloads,
+				}, slice.Concat(slice.Concat(query, expand), filters)),
+				Responses: responses("200", list, "400"),
+			}),
+		})
+
+		doc.add(path.Join("/", basePath, name, "_aggregate"), &PathItem{
+			Get: enabled(entity, model.OperationSearch, &Operation{
+				OperationID: fmt.Sprintf("aggregate_%s", name),
+				Summary:     fmt.Sprintf("Aggregate %s, by group", name),
+				Tags:        []string{name},
+				Parameters: slice.Concat([]*Parameter{
+					deepObject(params.Where, "equality filters by column", columns(entity, builder)),
+					{Name: params.GroupBy, In: "query", Description: "columns to group by, comma separated", Schema: &schema.Schema{Type: "string"}},
+					{Name: "count", In: "query", Description: "count the rows, the default without aggregates", Schema: &schema.Schema{Type: "boolean"}},
+					deepObject("sum", "sum of columns, by key", columns(entity, builder)),
+					deepObject("avg", "average of columns, by key", columns(entity, builder)),
+					deepObject("min", "min of columns, by key", columns(entity, builder)),
+					deepObject("max", "max of columns, by key", columns(entity, builder)),
+				}, filters),
+				Responses: responses("200", &schema.Schema{Type: "array", Items: &schema.Schema{Type: "object"}}, "400"),
+			}),
+		})
+
+		facet := &schema.Schema{Type: "object", Properties: map[string]*schema.Schema{
+			"value": {},
+			"count": {Type: "integer"},
+		}}
+
+		doc.add(path.Join("/", basePath, name, "_facets"), &PathItem{
+			Get: enabled(entity, model.OperationSearch, &Operation{
+				OperationID: fmt.Sprintf("facets_%s", name),
+				Summary:     fmt.Sprintf("Count the distinct values of %s fields", name),
+				Tags:        []string{name},
+				Parameters: slice.Concat([]*Parameter{
+					{Name: params.Field, In: "query", Required: true, Description: "columns to count the values of, repeatable", Schema: &schema.Schema{Type: "string"}},
+					{Name: params.Take, In: "query", Description: "max values per column", Schema: &schema.Schema{Type: "integer"}},
+					deepObject(params.Where, "equality filters by column", columns(entity, builder)),
+				}, slice.Concat(query, filters)),
+				Responses: responses("200", &schema.Schema{
+					Type:                 "object",
+					AdditionalProperties: &schema.Schema{Type: "array", Items: facet},
+				}, "400"),
+			}),
+		})
+
+		doc.add(path.Join("/", basePath, name, fmt.Sprintf("{%s}", params.ID)), &PathItem{
+			Get: enabled(entity, model.OperationRead, &Operation{
+				OperationID: fmt.Sprintf("read_%s", name),
+				Summary:     fmt.Sprintf("Read a %s", name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id, preloads}, expand),
+				Responses:   responses("200", it, "404"),
+			}),
+			Put: enabled(entity, model.OperationUpdate, &Operation{
+				OperationID: fmt.Sprintf("update_%s", name),
+				Summary:     fmt.Sprintf("Update a %s", name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id}, filters),
+				RequestBody: body(it),
+				Responses:   responses("200", it, "400", "409"),
+			}),
+			Delete: enabled(entity, model.OperationDelete, &Operation{
+				OperationID: fmt.Sprintf("delete_%s", name),
+				Summary:     fmt.Sprintf("Delete a %s", name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id}, filters),
+				Responses:   responses("200", nil, "409"),
+			}),
+			Patch: enabled(entity, model.OperationPatch, &Operation{
+				OperationID: fmt.Sprintf("patch_%s", name),
+				Summary:     fmt.Sprintf("Patch a %s, by column name", name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id, preloads}, filters),
+				RequestBody: body(&schema.Schema{Type: "object", Properties: columns(entity, builder)}),
+				Responses:   responses("200", it, "400", "409"),
+			}),
+		})
+	}
+
+	slice.ForEach(entities, func(entity model.Entity) {
+		if nested != nil && nested(entity) {
+			childRoutes(doc, builder, basePath, params, entity)
+		}
+	})
+
+	doc.Components = &Components{Schemas: builder.Definitions}
+
+	return doc
+}
+
+// childRoutes adds the routes of the child relations of the entity, ie /persons/{id}/pets
+func childRoutes(doc *Document, builder *schema.Builder, basePath string, params *Params, entity model.Entity) {
+	if model.KindOf(entity) != model.KindNormal {
+		return
+	}
+
+	sch, err := model.Schema(entity)
+
+	if err != nil {
+		return
+	}
+
+	name := entity.Name()
+	id := &Parameter{Name: params.ID, In: "path", Required: true, Schema: idSchema(entity)}
+	child := &Parameter{Name: "child", In: "path", Required: true, Schema: &schema.Schema{Type: "string"}}
+	filters := filterParameters(entity)
+
+	for _, relation := range sch.Relationships.Relations {
+		if relation.Type == gormschema.BelongsTo {
+			continue
+		}
+
+		field, _, _ := strings.Cut(relation.Field.Tag.Get("json"), ",")
+
+		if field == "" || field == "-" {
+			field = relation.Name
+		}
+
+		it := builder.Type(relation.FieldSchema.ModelType)
+		list := &schema.Schema{Type: "array", Items: it}
+		operation := fmt.Sprintf("%s_%s", name, field)
+		children := path.Join("/", basePath, name, fmt.Sprintf("{%s}", params.ID), field)
+
+		doc.add(children, &PathItem{
+			Get: enabled(entity, model.OperationRead, &Operation{
+				OperationID: fmt.Sprintf("list_%s", operation),
+				Summary:     fmt.Sprintf("List the %s of a %s", field, name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id}, filters),
+				Responses:   responses("200", list, "404"),
+			}),
+			Post: enabled(entity, model.OperationUpdate, &Operation{
+				OperationID: fmt.Sprintf("create_%s", operation),
+				Summary:     fmt.Sprintf("Create a %s child of a %s", field, name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id}, filters),
+				RequestBody: body(it),
+				Responses:   responses("201", it, "400", "404"),
+			}),
+		})
+
+		item := &PathItem{
+			Delete: enabled(entity, model.OperationUpdate, &Operation{
+				OperationID: fmt.Sprintf("delete_%s", operation),
+				Summary:     fmt.Sprintf("Delete a %s child of a %s, many to many children are only detached", field, name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id, child}, filters),
+				Responses:   responses("200", nil, "404"),
+			}),
+		}
+
+		if relation.Type == gormschema.Many2Many {
+			item.Put = enabled(entity, model.OperationUpdate, &Operation{
+				OperationID: fmt.Sprintf("attach_%s", operation),
+				Summary:     fmt.Sprintf("Attach an existing %s to a %s", field, name),
+				Tags:        []string{name},
+				Parameters:  slice.Concat([]*Parameter{id, child}, filters),
+				Responses:   responses("200", it, "404"),
+			})
+		}
+
+		doc.add(path.Join(children, "{child}"), item)
+	}
+}
+
+// add documents the path, unless none of its operations are routed
+func (d *Document) add(route string, item *PathItem) {
+	if item.Get == nil && item.Put == nil && item.Post == nil && item.Delete == nil && item.Patch == nil {
+		return
+	}
+
+	d.Paths[route] = item
+}
+
+// DefaultInfo is used when nothing else is provided
+func DefaultInfo() *Info {
+	return &Info{Title: "quickapi", Version: "1.0.0"}
+}
+
+// enabled returns the operation if it's enabled for the entity
+func enabled(entity model.Entity, operation model.Operation, it *Operation) *Operation {
+	if !model.Enabled(entity, operation) {
+		return nil
+	}
+
+	return it
+}
+
+func body(it *schema.Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]*MediaType{JSON: {Schema: it}},
+	}
+}
+
+func responses(success string, it *schema.Schema, failures ...string) map[string]*Response {
+	result := make(map[string]*Response)
+	result[success] = &Response{Description: "success"}
+
+	if it != nil {
+		result[success].Content = map[string]*MediaType{JSON: {Schema: it}}
+	}
+
+	for _, code := range failures {
+		result[code] = &Response{Description: description(code)}
+	}
+
+	result["500"] = &Response{Description: description("500")}
+
+	return result
+}
+
+func description(code string) string {
+	switch code {
+	case "400":
+		return "bad request"
+	case "404":
+		return "not found"
+	case "409":
+		return "conflict, nothing was changed"
+	default:
+		return "internal error"
+	}
+}
+
+// deepObject creates a parameter like name[key]=value
+func deepObject(name, description string, properties map[string]*schema.Schema) *Parameter {
+	explode := true
+	it := &schema.Schema{Type: "object", Properties: properties}
+
+	if len(properties) == 0 {
+		it.AdditionalProperties = &schema.Schema{Type: "string"}
+	}
+
+	return &Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Style:       "deepObject",
+		Explode:     &explode,
+		Schema:      it,
+	}
+}
+
+func preloadParameter(entity model.Entity, params *Params) *Parameter {
+	properties := make(map[string]*schema.Schema)
+
+	if discovery, ok := entity.(model.PreloadDiscovery); ok {
+		for _, alias := range discovery.Preloads() {
+			properties[alias] = &schema.Schema{Type: "string"}
+		}
+	}
+
+	return deepObject(params.Preload, "named preloads, with an optional condition value", properties)
+}
+
+// idSchema describes the ids of the entity by its id strategy
+func idSchema(entity model.Entity) *schema.Schema {
+	key, err := model.KeyOf(entity)
+
+	if err != nil {
+		return &schema.Schema{Type: "string"}
+	}
+
+	if key.Composite() {
+		return &schema.Schema{Type: "string", Description: strings.Join(key.Columns(), ",")}
+	}
+
+	switch key.Strategy {
+	case model.IDAuto:
+		return &schema.Schema{Type: "integer"}
+	case model.IDUUIDv4, model.IDUUIDv7:
+		return &schema.Schema{Type: "string", Format: "uuid"}
+	case model.IDULID:
+		return &schema.Schema{Type: "string", Description: "ulid"}
+	default:
+		return &schema.Schema{Type: "string"}
+	}
+}
+
+// expandParameters documents expand for entities that allow it, the conditions per relation are left out
+func expandParameters(entity model.Entity, params *Params) []*Parameter {
+	_, ok := entity.(model.ExpandSupport)
+
+	if params.Expand == "" || !ok || model.KindOf(entity) != model.KindNormal {
+		return nil
+	}
+
+	return []*Parameter{{
+		Name:        params.Expand,
+		In:          "query",
+		Description: "relations to expand, comma separated paths like pets.toys",
+		Schema:      &schema.Schema{Type: "string"},
+	}}
+}
+
+// queryParameters documents full text search for entities with searchable columns
+func queryParameters(entity model.Entity, params *Params) []*Parameter {
+	searchable, _ := model.SearchableOf(entity)
+
+	if params.Query == "" || len(searchable) == 0 {
+		return nil
+	}
+
+	return []*Parameter{{
+		Name:        params.Query,
+		In:          "query",
+		Description: fmt.Sprintf("full text search of %s, ordered by rank unless sorted", strings.Join(searchable, ", ")),
+		Schema:      &schema.Schema{Type: "string"},
+	}}
+}
+
+func filterParameters(entity model.Entity) []*Parameter {
+	scopeSupport, ok := entity.(model.ScopeSupport)
+
+	if !ok {
+		return nil
+	}
+
+	params := make([]*Parameter, 0)
+
+	for _, filter := range scopeSupport.Scopes() {
+		params = append(params, deepObject(filter.Name, "named filter", nil))
+	}
+
+	return params
+}
+
+// columns returns the schema of each column of the entity, json entities have no known columns
+func columns(entity model.Entity, builder *schema.Builder) map[string]*schema.Schema {
+	properties := make(map[string]*schema.Schema)
+
+	if model.KindOf(entity) == model.KindJson {
+		return properties
+	}
+
+	sch, err := model.Schema(entity)
+
+	if err != nil {
+		return properties
+	}
+
+	for _, field := range sch.Fields {
+		if field.DBName == "" {
+			continue
+		}
+
+		properties[field.DBName] = builder.Type(field.FieldType)
+	}
+
+	return properties
+}
+
+func sortColumns(entity model.Entity) map[string]*schema.Schema {
+	properties := make(map[string]*schema.Schema)
+
+	for name := range columns(entity, schema.NewBuilder("")) {
+		properties[name] = &schema.Schema{Type: "string", Enum: []any{"asc", "desc"}}
+	}
+
+	return properties
+}
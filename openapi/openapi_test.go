@@ -0,0 +1,77 @@
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Meduzz/quickapi/model"
+)
+
+type (
+	// ledger can be read and created, but not searched or changed
+	ledger struct {
+		ID    int64    `json:"id"`
+		Lines []*entry `gorm:"foreignKey:LedgerID" json:"lines"`
+	}
+
+	entry struct {
+		ID       int64 `json:"id"`
+		LedgerID int64 `json:"-"`
+	}
+)
+
+func (ledger) Name() string     { return "ledgers" }
+func (ledger) Create() any      { return &ledger{} }
+func (ledger) CreateArray() any { return make([]*ledger, 0) }
+func (ledger) Operations() []model.Operation {
+	return []model.Operation{model.OperationCreate, model.OperationRead}
+}
+
+func paths(doc *Document) []string {
+	result := make([]string, 0, len(doc.Paths))
+
+	for path := range doc.Paths {
+		result = append(result, path)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+func TestBuildOnlyDocumentsRoutes(t *testing.T) {
+	always := func(model.Entity) bool { return true }
+
+	tests := []struct {
+		name   string
+		nested func(model.Entity) bool
+		want   []string
+	}{
+		{"without nested routes", nil, []string{"/api/ledgers/", "/api/ledgers/{id}"}},
+		{"with nested routes", always, []string{"/api/ledgers/", "/api/ledgers/{id}", "/api/ledgers/{id}/lines"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc := Build(DefaultInfo(), "/api", DefaultParams(), test.nested, ledger{})
+
+			if got := paths(doc); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, got)
+			}
+
+			search := doc.Paths["/api/ledgers/"]
+
+			if search.Get != nil || search.Post == nil {
+				t.Fatalf("expected create only, got %+v", search)
+			}
+		})
+	}
+
+	// children are listed when the owner is read, the rest are updates of the owner
+	lines := Build(DefaultInfo(), "/api", DefaultParams(), always, ledger{}).Paths["/api/ledgers/{id}/lines"]
+
+	if lines.Get == nil || lines.Post != nil {
+		t.Fatalf("expected list only, got %+v", lines)
+	}
+}
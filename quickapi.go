@@ -4,48 +4,25 @@ import (
 	"errors"
 
 	"github.com/Meduzz/helper/fp/slice"
-	"github.com/Meduzz/quickapi/http"
 	"github.com/Meduzz/quickapi/model"
-	"github.com/gin-gonic/gin"
+	"github.com/Meduzz/quickapi/storage"
 	"github.com/spf13/cobra"
 	"gorm.io/gorm"
 )
 
+// GinStarter returns a cobra command that starts a quickapi over gin, see NewStarter to configure it further.
 func GinStarter(db *gorm.DB, entities ...model.Entity) *cobra.Command {
-	cmd := &cobra.Command{}
-
-	cmd.Use = "start"
-	cmd.Short = "start a quickapi over gin"
-	cmd.RunE = func(cmd *cobra.Command, args []string) error {
-		// start gin
-		engine := gin.Default()
-
-		err := Migrate(db, entities...)
-
-		if err != nil {
-			return err
-		}
-
-		config := http.DefaultConfig()
-
-		// iterate entities and create their api
-		err = http.For(db, &engine.RouterGroup, config, entities...)
-
-		if err != nil {
-			return err
-		}
-
-		return engine.Run(":8080")
-	}
-
-	return cmd
+	return NewStarter(db, entities...).Command()
 }
 
+// Migrate auto migrates the entities, and their child collections (see storage.MigrateChildren).
 func Migrate(db *gorm.DB, entities ...model.Entity) error {
 	errorz := slice.Map(entities, func(e model.Entity) error {
-		return db.Table(e.Name()).AutoMigrate(e.Create())
+		return storage.AutoMigrate(db, e)
 	})
 
+	errorz = append(errorz, storage.MigrateChildren(db, entities...))
+
 	return slice.Fold(errorz, nil, func(err, agg error) error {
 		if err != nil {
 			if agg != nil {
@@ -55,6 +32,6 @@ func Migrate(db *gorm.DB, entities ...model.Entity) error {
 			return err
 		}
 
-		return nil
+		return agg
 	})
 }
@@ -0,0 +1,376 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Meduzz/quickapi/model"
+)
+
+type (
+	// Schema is the subset of json schema that quickapi generates
+	Schema struct {
+		Schema               string             `json:"$schema,omitempty"`
+		Ref                  string             `json:"$ref,omitempty"`
+		Defs                 map[string]*Schema `json:"$defs,omitempty"`
+		Title                string             `json:"title,omitempty"`
+		Description          string             `json:"description,omitempty"`
+		Type                 any                `json:"type,omitempty"` // a type or a list of types
+		Format               string             `json:"format,omitempty"`
+		Properties           map[string]*Schema `json:"properties,omitempty"`
+		Required             []string           `json:"required,omitempty"`
+		Items                *Schema            `json:"items,omitempty"`
+		AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+		AnyOf                []*Schema          `json:"anyOf,omitempty"`
+		Enum                 []any              `json:"enum,omitempty"`
+		Minimum              *float64           `json:"minimum,omitempty"`
+		Maximum              *float64           `json:"maximum,omitempty"`
+		ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+		ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+		MinLength            *int               `json:"minLength,omitempty"`
+		MaxLength            *int               `json:"maxLength,omitempty"`
+		MinItems             *int               `json:"minItems,omitempty"`
+		MaxItems             *int               `json:"maxItems,omitempty"`
+	}
+
+	// Builder turns go types into schemas, named structs are collected
+	// in Definitions and referenced by prefix + name.
+	Builder struct {
+		prefix      string
+		Definitions map[string]*Schema
+	}
+)
+
+const (
+	Draft      = "https://json-schema.org/draft/2020-12/schema"
+	DefsPrefix = "#/$defs/"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// NewBuilder creates a builder, prefix is where the definitions will end up, ie #/components/schemas/
+func NewBuilder(prefix string) *Builder {
+	return &Builder{
+		prefix:      prefix,
+		Definitions: make(map[string]*Schema),
+	}
+}
+
+// Document creates a standalone json schema (draft 2020-12) of the entity,
+// with all referenced structs in $defs.
+func Document(entity model.Entity) *Schema {
+	return TypeDocument(reflect.TypeOf(entity.Create()))
+}
+
+// TypeDocument creates a standalone json schema (draft 2020-12) of t.
+func TypeDocument(t reflect.Type) *Schema {
+	builder := NewBuilder(DefsPrefix)
+	it := builder.Type(t)
+	it.Schema = Draft
+	it.Defs = builder.Definitions
+
+	return it
+}
+
+// Entity creates the schema of the entity (a reference to it's definition)
+func (b *Builder) Entity(entity model.Entity) *Schema {
+	return b.Type(reflect.TypeOf(entity.Create()))
+}
+
+// Type creates the schema of t
+func (b *Builder) Type(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	if reflect.PointerTo(t).Implements(marshalerType) {
+		// no idea what it turns into
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+
+		return &Schema{Type: "array", Items: b.Type(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.Type(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+
+		return b.reference(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// reference adds the struct to the definitions (once) and returns a reference to it
+func (b *Builder) reference(t reflect.Type) *Schema {
+	name := t.Name()
+	ref := &Schema{Ref: b.prefix + name}
+
+	if _, ok := b.Definitions[name]; ok {
+		return ref
+	}
+
+	// placeholder to stop recursive types
+	b.Definitions[name] = &Schema{}
+	b.Definitions[name] = b.object(t)
+
+	return ref
+}
+
+func (b *Builder) object(t reflect.Type) *Schema {
+	it := &Schema{
+		Title:      t.Name(),
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	b.fields(t, it)
+
+	return it
+}
+
+func (b *Builder) fields(t reflect.Type, it *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, skip := jsonName(field)
+
+		if skip {
+			continue
+		}
+
+		// embedded structs without a json name are flattened, like encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				b.fields(embedded, it)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := b.Type(field.Type)
+		b.rules(field, property)
+
+		if field.Type.Kind() == reflect.Pointer {
+			property = nullable(property)
+		}
+
+		it.Properties[name] = property
+
+		if isRequired(field) {
+			it.Required = append(it.Required, name)
+		}
+	}
+}
+
+// rules applies constraints from the gorm and binding tags
+func (b *Builder) rules(field reflect.StructField, property *Schema) {
+	for _, setting := range strings.Split(field.Tag.Get("gorm"), ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(setting), ":")
+
+		if strings.EqualFold(key, "size") && property.Type == "string" {
+			size, err := strconv.Atoi(value)
+
+			if err == nil {
+				property.MaxLength = &size
+			}
+		}
+	}
+
+	omitempty := false
+
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		if key == "dive" {
+			// the remaining rules are about the items
+			break
+		}
+
+		if key == "omitempty" {
+			omitempty = true
+		}
+
+		binding(key, value, property)
+	}
+
+	// omitempty lets the zero value skip the other rules
+	if omitempty && len(property.Enum) > 0 {
+		property.Enum = append(property.Enum, typed(property, reflect.Zero(field.Type).Interface()))
+	}
+}
+
+// binding applies a validator rule (see gin binding) to property, unknown rules are ignored.
+func binding(key, value string, property *Schema) {
+	switch key {
+	case "email":
+		property.Format = "email"
+	case "url", "uri":
+		property.Format = "uri"
+	case "uuid":
+		property.Format = "uuid"
+	case "oneof":
+		for _, it := range strings.Fields(value) {
+			property.Enum = append(property.Enum, typed(property, it))
+		}
+	case "len", "min", "max", "gt", "gte", "lt", "lte":
+		limit, err := strconv.ParseFloat(value, 64)
+
+		if err != nil {
+			return
+		}
+
+		switch property.Type {
+		case "integer", "number":
+			numeric(key, limit, property)
+		case "string":
+			property.MinLength, property.MaxLength = lengths(key, int(limit), property.MinLength, property.MaxLength)
+		case "array", "object":
+			property.MinItems, property.MaxItems = lengths(key, int(limit), property.MinItems, property.MaxItems)
+		}
+	}
+}
+
+func numeric(key string, limit float64, property *Schema) {
+	switch key {
+	case "len":
+		property.Minimum = &limit
+		property.Maximum = &limit
+	case "min", "gte":
+		property.Minimum = &limit
+	case "max", "lte":
+		property.Maximum = &limit
+	case "gt":
+		property.ExclusiveMinimum = &limit
+	case "lt":
+		property.ExclusiveMaximum = &limit
+	}
+}
+
+// lengths returns the new min and max length, strings, slices and maps are limited by length in binding rules.
+func lengths(key string, limit int, min, max *int) (*int, *int) {
+	switch key {
+	case "len":
+		return ptr(limit), ptr(limit)
+	case "min", "gte":
+		return ptr(limit), max
+	case "max", "lte":
+		return min, ptr(limit)
+	case "gt":
+		return ptr(limit + 1), max
+	case "lt":
+		return min, ptr(limit - 1)
+	}
+
+	return min, max
+}
+
+// typed turns an enum value into the type of property
+func typed(property *Schema, value any) any {
+	text := fmt.Sprint(value)
+
+	switch property.Type {
+	case "integer":
+		it, err := strconv.ParseInt(text, 10, 64)
+
+		if err == nil {
+			return it
+		}
+	case "number":
+		it, err := strconv.ParseFloat(text, 64)
+
+		if err == nil {
+			return it
+		}
+	}
+
+	return text
+}
+
+// nullable allows null as well as the schema
+func nullable(it *Schema) *Schema {
+	if it.Ref != "" {
+		return &Schema{AnyOf: []*Schema{it, {Type: "null"}}}
+	}
+
+	if kind, ok := it.Type.(string); ok {
+		it.Type = []string{kind, "null"}
+	}
+
+	return it
+}
+
+// jsonName returns the json name of the field and if it should be skipped
+func jsonName(field reflect.StructField) (string, bool) {
+	tag, ok := field.Tag.Lookup("json")
+
+	if !ok {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+
+	if name == "-" {
+		return "", true
+	}
+
+	return strings.TrimSpace(name), false
+}
+
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ptr[T any](it T) *T {
+	return &it
+}
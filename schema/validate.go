@@ -0,0 +1,246 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+	// ValidationError describes the first part of a value that did not match the schema
+	ValidationError struct {
+		Path    string // ie $.pets[0].name
+		Message string
+	}
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", e.Path, e.Message)
+}
+
+// Validate checks a value decoded by encoding/json against the schema,
+// references are resolved from $defs of the schema. Returns a *ValidationError.
+func (s *Schema) Validate(value any) error {
+	return s.ValidatePartial(value, false)
+}
+
+// ValidatePartial works like Validate, but when partial is set the required
+// properties of the root object may be left out, like in a patch.
+func (s *Schema) ValidatePartial(value any, partial bool) error {
+	return s.validate(s, "$", value, partial)
+}
+
+func (s *Schema) validate(root *Schema, path string, value any, partial bool) error {
+	if s.Ref != "" {
+		def, ok := root.Defs[strings.TrimPrefix(s.Ref, DefsPrefix)]
+
+		if !ok {
+			return invalid(path, "references unknown schema %s", s.Ref)
+		}
+
+		return def.validate(root, path, value, partial)
+	}
+
+	if len(s.AnyOf) > 0 {
+		matched := slices.ContainsFunc(s.AnyOf, func(it *Schema) bool {
+			return it.validate(root, path, value, partial) == nil
+		})
+
+		if !matched {
+			return invalid(path, "matches none of the allowed schemas")
+		}
+	}
+
+	if !s.allows(typeOf(value)) {
+		return invalid(path, "must be of type %v", s.Type)
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(it any) bool { return fmt.Sprint(it) == fmt.Sprint(value) }) {
+		return invalid(path, "must be one of %v", s.Enum)
+	}
+
+	switch it := value.(type) {
+	case float64:
+		return s.number(path, it)
+	case string:
+		return s.text(path, it)
+	case []any:
+		if err := s.length(path, len(it), s.MinItems, s.MaxItems, "items"); err != nil {
+			return err
+		}
+
+		if s.Items == nil {
+			return nil
+		}
+
+		for i, item := range it {
+			err := s.Items.validate(root, fmt.Sprintf("%s[%d]", path, i), item, false)
+
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		return s.object(root, path, it, partial)
+	}
+
+	return nil
+}
+
+func (s *Schema) object(root *Schema, path string, value map[string]any, partial bool) error {
+	if err := s.length(path, len(value), s.MinItems, s.MaxItems, "properties"); err != nil {
+		return err
+	}
+
+	if !partial {
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				return invalid(path, "is missing required property %s", name)
+			}
+		}
+	}
+
+	for name, property := range value {
+		it, ok := s.Properties[name]
+
+		if !ok {
+			it = s.AdditionalProperties
+		}
+
+		if it == nil {
+			// unknown properties are ignored, like encoding/json does
+			continue
+		}
+
+		err := it.validate(root, fmt.Sprintf("%s.%s", path, name), property, false)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) number(path string, value float64) error {
+	switch {
+	case s.Minimum != nil && value < *s.Minimum:
+		return invalid(path, "must be at least %v", *s.Minimum)
+	case s.Maximum != nil && value > *s.Maximum:
+		return invalid(path, "must be at most %v", *s.Maximum)
+	case s.ExclusiveMinimum != nil && value <= *s.ExclusiveMinimum:
+		return invalid(path, "must be greater than %v", *s.ExclusiveMinimum)
+	case s.ExclusiveMaximum != nil && value >= *s.ExclusiveMaximum:
+		return invalid(path, "must be less than %v", *s.ExclusiveMaximum)
+	}
+
+	return nil
+}
+
+func (s *Schema) text(path string, value string) error {
+	err := s.length(path, utf8.RuneCountInString(value), s.MinLength, s.MaxLength, "characters")
+
+	if err != nil {
+		return err
+	}
+
+	if !validFormat(s.Format, value) {
+		return invalid(path, "must be formatted as %s", s.Format)
+	}
+
+	return nil
+}
+
+func (s *Schema) length(path string, length int, min, max *int, unit string) error {
+	if min != nil && length < *min {
+		return invalid(path, "must have at least %d %s", *min, unit)
+	}
+
+	if max != nil && length > *max {
+		return invalid(path, "must have at most %d %s", *max, unit)
+	}
+
+	return nil
+}
+
+// allows checks if the json type is allowed by the schema, integers are numbers too.
+func (s *Schema) allows(kind string) bool {
+	var types []string
+
+	switch it := s.Type.(type) {
+	case string:
+		types = []string{it}
+	case []string:
+		types = it
+	default:
+		return true
+	}
+
+	if kind == "integer" && slices.Contains(types, "number") {
+		return true
+	}
+
+	return slices.Contains(types, kind)
+}
+
+func typeOf(value any) string {
+	switch it := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if it == math.Trunc(it) && !math.IsInf(it, 0) {
+			return "integer"
+		}
+
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// validFormat checks the formats the builder produces, others are accepted as is.
+func validFormat(format, value string) bool {
+	var err error
+
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	case "date":
+		_, err = time.Parse(time.DateOnly, value)
+	case "time":
+		_, err = time.Parse(time.TimeOnly, value)
+	case "email":
+		_, err = mail.ParseAddress(value)
+	case "uri":
+		var it *url.URL
+		it, err = url.Parse(value)
+
+		if err == nil && it.Scheme == "" {
+			return false
+		}
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	}
+
+	return err == nil
+}
+
+func invalid(path, format string, args ...any) error {
+	return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type account struct {
+	ID     int64      `json:"id"`
+	Email  string     `json:"email" binding:"required,email"`
+	Nick   string     `json:"nick" binding:"min=2,max=5"`
+	Age    int        `json:"age" binding:"gte=0,lt=150"`
+	Role   string     `json:"role" binding:"omitempty,oneof=admin user"`
+	Born   *time.Time `json:"born"`
+	Tags   []string   `json:"tags" binding:"max=2"`
+	Friend *account   `json:"friend"`
+}
+
+func (account) Name() string     { return "accounts" }
+func (account) Create() any      { return &account{} }
+func (account) CreateArray() any { return make([]*account, 0) }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		partial bool
+		path    string // empty when valid
+	}{
+		{"valid", `{"email":"a@b.se","nick":"ann","age":30,"role":"admin","born":"2000-01-02T03:04:05Z","tags":["x"]}`, false, ""},
+		{"nulls", `{"email":"a@b.se","born":null,"friend":null}`, false, ""},
+		{"unknown properties", `{"email":"a@b.se","nickname":"x"}`, false, ""},
+		{"empty role", `{"email":"a@b.se","role":""}`, false, ""},
+		{"missing required", `{"nick":"ann"}`, false, "$"},
+		{"missing required in a patch", `{"nick":"ann"}`, true, ""},
+		{"not an object", `[]`, false, "$"},
+		{"bad email", `{"email":"nope"}`, false, "$.email"},
+		{"too short", `{"email":"a@b.se","nick":"a"}`, false, "$.nick"},
+		{"too long", `{"email":"a@b.se","nick":"annabel"}`, false, "$.nick"},
+		{"negative", `{"email":"a@b.se","age":-1}`, false, "$.age"},
+		{"too old", `{"email":"a@b.se","age":150}`, false, "$.age"},
+		{"not an integer", `{"email":"a@b.se","age":1.5}`, false, "$.age"},
+		{"a string for a number", `{"email":"a@b.se","age":"1"}`, false, "$.age"},
+		{"not one of", `{"email":"a@b.se","role":"root"}`, false, "$.role"},
+		{"not a date", `{"email":"a@b.se","born":"yesterday"}`, false, "$.born"},
+		{"too many items", `{"email":"a@b.se","tags":["x","y","z"]}`, false, "$.tags"},
+		{"bad item", `{"email":"a@b.se","tags":[1]}`, false, "$.tags[0]"},
+		{"bad reference", `{"email":"a@b.se","friend":{"email":"nope"}}`, false, "$.friend"}, // nullable, so any of the reference or null
+		{"required in a reference of a patch", `{"friend":{"nick":"bob"}}`, true, "$.friend"},
+	}
+
+	document := Document(account{})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value any
+			err := json.Unmarshal([]byte(test.body), &value)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = document.ValidatePartial(value, test.partial)
+
+			if test.path == "" {
+				if err != nil {
+					t.Fatalf("expected it to be valid, got %v", err)
+				}
+
+				return
+			}
+
+			invalid := &ValidationError{}
+
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected a validation error at %s, got %v", test.path, err)
+			}
+
+			if invalid.Path != test.path {
+				t.Fatalf("expected a validation error at %s, got %v", test.path, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package quickapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/quickapi/http"
+	"github.com/Meduzz/quickapi/model"
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gorm.io/gorm"
+)
+
+type (
+	// Settings of the starter, read from flags, env (QUICKAPI_<FLAG>) and a config file, in that order.
+	Settings struct {
+		Addr     string     `json:"addr" yaml:"addr" toml:"addr"`
+		BasePath string     `json:"base_path" yaml:"base_path" toml:"base_path"`
+		Mode     string     `json:"mode" yaml:"mode" toml:"mode"`             // gin mode, debug, release or test
+		Migrate  bool       `json:"migrate" yaml:"migrate" toml:"migrate"`    // apply the registered migrations, then auto migrate the entities
+		Take     int        `json:"take" yaml:"take" toml:"take"`             // default take of searches
+		MaxTake  int        `json:"max_take" yaml:"max_take" toml:"max_take"` // 0 means no limit
+		CORS     *http.CORS `json:"cors" yaml:"cors" toml:"cors"`
+		Database *Database  `json:"database" yaml:"database" toml:"database"` // used when the starter has no db
+		Drain    Duration   `json:"drain" yaml:"drain" toml:"drain"`          // max time to drain requests and stop components on shutdown
+		TLS      *TLS       `json:"tls" yaml:"tls" toml:"tls"`                // serve https when a cert and key are set
+	}
+
+	// Starter starts a quickapi over gin, with settings from flags, env and config file.
+	Starter struct {
+		db          *gorm.DB
+		opened      bool      // the db was opened from the settings, and is closed on shutdown
+		out         io.Writer // what the starter did, ie applied migrations, is printed here
+		entities    []model.Entity
+		configurers []http.Configurer
+		middleware  []gin.HandlerFunc
+		components  []component
+	}
+
+	// setting binds a flag to a field of Settings
+	setting struct {
+		name  string
+		apply func(from, to *Settings)
+	}
+)
+
+const EnvPrefix = "QUICKAPI_"
+
+// NewStarter creates a starter for the entities, db can be nil to open it from the settings
+func NewStarter(db *gorm.DB, entities ...model.Entity) *Starter {
+	return &Starter{
+		db:       db,
+		entities: entities,
+	}
+}
+
+// DefaultSettings are used when nothing else is set
+func DefaultSettings() *Settings {
+	return &Settings{
+		Addr:    ":8080",
+		Mode:    gin.DebugMode,
+		Migrate: true,
+		Take:    25,
+		Drain:   Duration(10 * time.Second),
+		Database: &Database{
+			RetryDelay: Duration(2 * time.Second),
+		},
+	}
+}
+
+// Configure adds configurers, applied after the settings to the http.DefaultConfig
+func (s *Starter) Configure(configurers ...http.Configurer) *Starter {
+	s.configurers = append(s.configurers, configurers...)
+	return s
+}
+
+// Output sets where the starter prints what it did, ie applied migrations. Defaults to stdout.
+func (s *Starter) Output(out io.Writer) *Starter {
+	s.out = out
+	return s
+}
+
+// Use adds gin middleware, in front of the api
+func (s *Starter) Use(middleware ...gin.HandlerFunc) *Starter {
+	s.middleware = append(s.middleware, middleware...)
+	return s
+}
+
+// Command returns the cobra command that starts the server
+func (s *Starter) Command() *cobra.Command {
+	cmd := &cobra.Command{}
+
+	cmd.Use = "start"
+	cmd.Short = "start a quickapi over gin"
+
+	read := s.bind(cmd.Flags())
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		settings, err := read()
+
+		if err != nil {
+			return err
+		}
+
+		if s.out == nil {
+			s.Output(cmd.OutOrStdout())
+		}
+
+		return s.Start(settings)
+	}
+
+	return cmd
+}
+
+// bind adds the flags of the settings, the returned func reads the settings once the flags are parsed
+func (s *Starter) bind(flags *pflag.FlagSet) func() (*Settings, error) {
+	flagged := DefaultSettings()
+	flagged.CORS = &http.CORS{}
+	flagged.TLS = &TLS{}
+
+	file := flags.String("config", "", "yaml or toml config file")
+	flags.StringVar(&flagged.Addr, "addr", flagged.Addr, "address to listen on")
+	flags.StringVar(&flagged.BasePath, "base-path", flagged.BasePath, "path to serve the api under")
+	flags.StringVar(&flagged.Mode, "mode", flagged.Mode, "gin mode, debug, release or test")
+	flags.BoolVar(&flagged.Migrate, "migrate", flagged.Migrate, "migrate the entities on start")
+	flags.IntVar(&flagged.Take, "take", flagged.Take, "default take of searches")
+	flags.IntVar(&flagged.MaxTake, "max-take", flagged.MaxTake, "max take of searches, 0 means no limit")
+	flags.StringSliceVar(&flagged.CORS.Origins, "cors-origin", nil, "allowed CORS origins, * for all")
+	flags.DurationVar((*time.Duration)(&flagged.Drain), "drain", time.Duration(flagged.Drain), "max time to drain requests and stop components on shutdown")
+	flags.StringVar(&flagged.TLS.Cert, "tls-cert", "", "cert file to serve https with, reloaded on change")
+	flags.StringVar(&flagged.TLS.Key, "tls-key", "", "key file of the cert, reloaded on change")
+	flags.StringVar(&flagged.TLS.ClientCA, "tls-client-ca", "", "CA file that client certs must be signed by (mTLS)")
+	flags.BoolVar(&flagged.TLS.HTTP3, "http3", false, "also serve HTTP/3 over udp, requires tls")
+	flags.StringVar(&flagged.Database.DSN, "dsn", "", "db to open when none was given, ie sqlite://test.db")
+	flags.IntVar(&flagged.Database.MaxOpen, "db-max-open", 0, "max open connections, 0 means no limit")
+	flags.IntVar(&flagged.Database.MaxIdle, "db-max-idle", 0, "max idle connections, 0 means the driver default")
+	flags.DurationVar((*time.Duration)(&flagged.Database.MaxLifetime), "db-max-lifetime", 0, "max lifetime of a connection, 0 means forever")
+	flags.IntVar(&flagged.Database.Retries, "db-retries", 0, "retries when the db can't be opened on start")
+	flags.DurationVar((*time.Duration)(&flagged.Database.RetryDelay), "db-retry-delay", time.Duration(flagged.Database.RetryDelay), "delay between retries")
+
+	return func() (*Settings, error) {
+		return s.settings(flags, *file, flagged)
+	}
+}
+
+// Start serves the entities with the settings until SIGINT or SIGTERM, see Serve
+func (s *Starter) Start(settings *Settings) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.Serve(ctx, settings)
+}
+
+// Serve opens the db (if needed), migrates (if enabled, see migrate.Register), starts the background components
+// and serves the entities with the settings until ctx is done, then shuts down gracefully.
+func (s *Starter) Serve(ctx context.Context, settings *Settings) error {
+	gin.SetMode(settings.Mode)
+
+	err := s.connect(settings)
+
+	if err != nil {
+		return err
+	}
+
+	if settings.Migrate {
+		err = upgrade(ctx, s.output(), s.db, s.entities)
+
+		if err != nil {
+			return errors.Join(err, s.close())
+		}
+	}
+
+	engine, err := s.Engine(settings)
+
+	if err != nil {
+		return errors.Join(err, s.close())
+	}
+
+	server := &nethttp.Server{
+		Addr:    settings.Addr,
+		Handler: engine,
+	}
+
+	servers := []shutdowner{server}
+	served := make(chan error, 2)
+	listen := server.ListenAndServe
+
+	if settings.TLS.enabled() {
+		config, err := settings.TLS.Config()
+
+		if err != nil {
+			return errors.Join(err, s.close())
+		}
+
+		server.TLSConfig = config
+		listen = func() error {
+			return server.ListenAndServeTLS("", "")
+		}
+
+		if settings.TLS.HTTP3 {
+			h3 := &http3.Server{
+				Addr:      settings.Addr,
+				Handler:   engine,
+				TLSConfig: http3.ConfigureTLSConfig(config),
+			}
+
+			// tell clients of the tcp server that HTTP/3 is available
+			server.Handler = nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				h3.SetQUICHeaders(w.Header())
+				engine.ServeHTTP(w, r)
+			})
+
+			servers = append(servers, h3)
+
+			go func() {
+				served <- h3.ListenAndServe()
+			}()
+		}
+	}
+
+	stops := s.start(ctx)
+
+	go func() {
+		served <- listen()
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-served:
+	}
+
+	return errors.Join(err, s.shutdown(servers, stops, settings.Drain))
+}
+
+// Engine creates the gin engine serving the entities with the settings
+func (s *Starter) Engine(settings *Settings) (*gin.Engine, error) {
+	err := s.connect(settings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	engine := gin.New()
+	engine.Use(gin.Logger(), gin.Recovery())
+
+	if settings.CORS != nil && len(settings.CORS.Origins) > 0 {
+		engine.Use(http.Cors(settings.CORS))
+	}
+
+	if settings.TLS.enabled() && settings.TLS.ClientCA != "" {
+		engine.Use(http.ClientCertPrincipal())
+	}
+
+	engine.Use(s.middleware...)
+
+	config := http.DefaultConfig()
+	http.WithTakeQueryIntStrategy(http.TAKE, settings.Take)(config)
+
+	if settings.MaxTake > 0 {
+		http.WithMaxTake(settings.MaxTake)(config)
+	}
+
+	slice.ForEach(s.configurers, func(configurer http.Configurer) {
+		configurer(config)
+	})
+
+	group := engine.Group(settings.BasePath)
+	err = http.For(s.db, group, config, s.entities...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return engine, nil
+}
+
+func (s *Starter) output() io.Writer {
+	if s.out == nil {
+		return os.Stdout
+	}
+
+	return s.out
+}
+
+// connect opens the db from the settings, unless the starter was given one
+func (s *Starter) connect(settings *Settings) error {
+	if s.db != nil {
+		return nil
+	}
+
+	db, err := Open(settings.Database)
+
+	if err != nil {
+		return err
+	}
+
+	s.db = db
+	s.opened = true
+
+	return nil
+}
+
+// settings are the defaults, overridden by the config file, env and flags in that order
+func (s *Starter) settings(flags *pflag.FlagSet, file string, flagged *Settings) (*Settings, error) {
+	settings := DefaultSettings()
+
+	if file == "" {
+		file = os.Getenv(EnvPrefix + "CONFIG")
+	}
+
+	if file != "" {
+		err := readSettings(file, settings)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, it := range settingFlags() {
+		flag := flags.Lookup(it.name)
+
+		if flag == nil {
+			continue
+		}
+
+		if !flag.Changed {
+			value, ok := os.LookupEnv(envName(it.name))
+
+			if !ok {
+				continue
+			}
+
+			err := flags.Set(it.name, value)
+
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", envName(it.name), err)
+			}
+		}
+
+		it.apply(flagged, settings)
+	}
+
+	return settings, nil
+}
+
+// readSettings reads a yaml or toml file (by extension) into settings
+func readSettings(file string, settings *Settings) error {
+	bs, err := os.ReadFile(file)
+
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(bs, settings)
+	case ".toml":
+		return toml.Unmarshal(bs, settings)
+	default:
+		return fmt.Errorf("unknown config format %s, use yaml or toml", file)
+	}
+}
+
+func settingFlags() []*setting {
+	return []*setting{
+		{"addr", func(from, to *Settings) { to.Addr = from.Addr }},
+		{"base-path", func(from, to *Settings) { to.BasePath = from.BasePath }},
+		{"mode", func(from, to *Settings) { to.Mode = from.Mode }},
+		{"migrate", func(from, to *Settings) { to.Migrate = from.Migrate }},
+		{"take", func(from, to *Settings) { to.Take = from.Take }},
+		{"max-take", func(from, to *Settings) { to.MaxTake = from.MaxTake }},
+		{"cors-origin", func(from, to *Settings) {
+			if to.CORS == nil {
+				to.CORS = &http.CORS{}
+			}
+
+			to.CORS.Origins = from.CORS.Origins
+		}},
+		{"drain", func(from, to *Settings) { to.Drain = from.Drain }},
+		{"tls-cert", func(from, to *Settings) { to.tls().Cert = from.TLS.Cert }},
+		{"tls-key", func(from, to *Settings) { to.tls().Key = from.TLS.Key }},
+		{"tls-client-ca", func(from, to *Settings) { to.tls().ClientCA = from.TLS.ClientCA }},
+		{"http3", func(from, to *Settings) { to.tls().HTTP3 = from.TLS.HTTP3 }},
+		{"dsn", func(from, to *Settings) { to.database().DSN = from.Database.DSN }},
+		{"db-max-open", func(from, to *Settings) { to.database().MaxOpen = from.Database.MaxOpen }},
+		{"db-max-idle", func(from, to *Settings) { to.database().MaxIdle = from.Database.MaxIdle }},
+		{"db-max-lifetime", func(from, to *Settings) { to.database().MaxLifetime = from.Database.MaxLifetime }},
+		{"db-retries", func(from, to *Settings) { to.database().Retries = from.Database.Retries }},
+		{"db-retry-delay", func(from, to *Settings) { to.database().RetryDelay = from.Database.RetryDelay }},
+	}
+}
+
+// database returns the database settings, created when the config file had none
+func (s *Settings) database() *Database {
+	if s.Database == nil {
+		s.Database = &Database{}
+	}
+
+	return s.Database
+}
+
+// tls returns the tls settings, created when the config file had none
+func (s *Settings) tls() *TLS {
+	if s.TLS == nil {
+		s.TLS = &TLS{}
+	}
+
+	return s.TLS
+}
+
+// envName turns a flag into its env var, ie base-path into QUICKAPI_BASE_PATH
+func envName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
@@ -0,0 +1,134 @@
+package quickapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// picked is the part of the settings checked by TestSettingsPrecedence
+type picked struct {
+	Addr    string
+	Take    int
+	Migrate bool
+	Drain   time.Duration
+	DSN     string
+	Origins []string
+}
+
+func pick(settings *Settings) picked {
+	it := picked{
+		Addr:    settings.Addr,
+		Take:    settings.Take,
+		Migrate: settings.Migrate,
+		Drain:   time.Duration(settings.Drain),
+	}
+
+	if settings.Database != nil {
+		it.DSN = settings.Database.DSN
+	}
+
+	if settings.CORS != nil {
+		it.Origins = settings.CORS.Origins
+	}
+
+	return it
+}
+
+func TestSettingsPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.yaml": "addr: :1\ntake: 10\nmigrate: false\ndrain: 5s\ndatabase:\n  dsn: sqlite://file.db\ncors:\n  origins: [\"https://file\"]\n",
+		"app.toml": "addr = \":1\"\ntake = 10\nmigrate = false\ndrain = \"5s\"\n\n[database]\ndsn = \"sqlite://file.db\"\n",
+		"app.json": "{}",
+	}
+
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	yamlFile := filepath.Join(dir, "app.yaml")
+	tomlFile := filepath.Join(dir, "app.toml")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want picked
+	}{
+		{"defaults", nil, nil, picked{":8080", 25, true, 10 * time.Second, "", nil}},
+		{"yaml file", nil, []string{"--config", yamlFile}, picked{":1", 10, false, 5 * time.Second, "sqlite://file.db", []string{"https://file"}}},
+		{"toml file", nil, []string{"--config", tomlFile}, picked{":1", 10, false, 5 * time.Second, "sqlite://file.db", nil}},
+		{"file from env", map[string]string{"QUICKAPI_CONFIG": yamlFile}, nil, picked{":1", 10, false, 5 * time.Second, "sqlite://file.db", []string{"https://file"}}},
+		{"env over file", map[string]string{"QUICKAPI_ADDR": ":2", "QUICKAPI_MIGRATE": "true", "QUICKAPI_DSN": "sqlite://env.db", "QUICKAPI_CORS_ORIGIN": "https://a,https://b"}, []string{"--config", yamlFile}, picked{":2", 10, true, 5 * time.Second, "sqlite://env.db", []string{"https://a", "https://b"}}},
+		{"env over defaults", map[string]string{"QUICKAPI_TAKE": "50", "QUICKAPI_DRAIN": "1m"}, nil, picked{":8080", 50, true, time.Minute, "", nil}},
+		{"flags over env and file", map[string]string{"QUICKAPI_ADDR": ":2", "QUICKAPI_TAKE": "50"}, []string{"--config", yamlFile, "--addr", ":3", "--drain", "1s", "--migrate=true"}, picked{":3", 50, true, time.Second, "sqlite://file.db", []string{"https://file"}}},
+		{"flag set to the default over file", nil, []string{"--config", yamlFile, "--take", "25"}, picked{":1", 25, false, 5 * time.Second, "sqlite://file.db", []string{"https://file"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+
+			flags := pflag.NewFlagSet("start", pflag.ContinueOnError)
+			read := NewStarter(nil).bind(flags)
+			err := flags.Parse(test.args)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			settings, err := read()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := pick(settings); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+
+	errors := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{"missing file", nil, []string{"--config", filepath.Join(dir, "missing.yaml")}},
+		{"unknown format", nil, []string{"--config", filepath.Join(dir, "app.json")}},
+		{"bad env", map[string]string{"QUICKAPI_TAKE": "many"}, nil},
+	}
+
+	for _, test := range errors {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+
+			flags := pflag.NewFlagSet("start", pflag.ContinueOnError)
+			read := NewStarter(nil).bind(flags)
+			err := flags.Parse(test.args)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = read()
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// ChildModels returns the models of the child collections (has one, has many & many to many) of the entities,
+// and of their children, that aren't entities themselves. They are not migrated by AutoMigrate of the owner.
+func ChildModels(entities ...model.Entity) []any {
+	seen := make(map[reflect.Type]bool)
+	schemas := make([]*schema.Schema, 0)
+
+	for _, entity := range entities {
+		if model.KindOf(entity) == model.KindJson {
+			continue
+		}
+
+		sch, err := model.Schema(entity)
+
+		if err != nil {
+			// reported when the entity is migrated
+			continue
+		}
+
+		seen[sch.ModelType] = true
+		schemas = append(schemas, sch)
+	}
+
+	result := make([]any, 0)
+
+	var visit func(sch *schema.Schema)
+	visit = func(sch *schema.Schema) {
+		for _, relation := range sch.Relationships.Relations {
+			if relation.Type == schema.BelongsTo || seen[relation.FieldSchema.ModelType] {
+				continue
+			}
+
+			seen[relation.FieldSchema.ModelType] = true
+			result = append(result, reflect.New(relation.FieldSchema.ModelType).Interface())
+			visit(relation.FieldSchema)
+		}
+	}
+
+	for _, sch := range schemas {
+		visit(sch)
+	}
+
+	return result
+}
+
+// AutoMigrate auto migrates the entity under its name, and its full text index (see MigrateSearch). Entities with many to many relations are migrated
+// without relations and their join tables on their own, gorm would migrate the join tables under the name of the entity.
+func AutoMigrate(db *gorm.DB, entity model.Entity) error {
+	err := autoMigrate(db, entity)
+
+	if err != nil {
+		return err
+	}
+
+	return MigrateSearch(db, entity)
+}
+
+func autoMigrate(db *gorm.DB, entity model.Entity) error {
+	if model.KindOf(entity) == model.KindJson {
+		return db.Table(entity.Name()).AutoMigrate(MigrationModel(entity))
+	}
+
+	sch, err := model.Schema(entity)
+
+	if err != nil {
+		return err
+	}
+
+	joins := joinTables(sch)
+
+	if len(joins) == 0 {
+		return db.Table(entity.Name()).AutoMigrate(MigrationModel(entity))
+	}
+
+	err = withoutRelations(db).Table(entity.Name()).AutoMigrate(MigrationModel(entity))
+
+	if err != nil {
+		return err
+	}
+
+	return migrateJoinTables(db, joins)
+}
+
+// MigrateChildren auto migrates the ChildModels of the entities, and their join tables. The other relations of the children
+// (ie foreign keys to the owner) are not migrated, gorm can't resolve owners with a table name of their own.
+func MigrateChildren(db *gorm.DB, entities ...model.Entity) error {
+	children := ChildModels(entities...)
+	err := withoutRelations(db).AutoMigrate(children...)
+
+	if err != nil {
+		return err
+	}
+
+	joins := make(map[string]any)
+
+	for _, child := range children {
+		sch, err := schema.Parse(child, &sync.Map{}, schema.NamingStrategy{})
+
+		if err != nil {
+			return err
+		}
+
+		maps.Copy(joins, joinTables(sch))
+	}
+
+	return migrateJoinTables(db, joins)
+}
+
+// joinTables returns the join models of the many to many relations by table
+func joinTables(sch *schema.Schema) map[string]any {
+	result := make(map[string]any)
+
+	for _, relation := range sch.Relationships.Relations {
+		if relation.JoinTable != nil {
+			result[relation.JoinTable.Table] = reflect.New(relation.JoinTable.ModelType).Interface()
+		}
+	}
+
+	return result
+}
+
+func migrateJoinTables(db *gorm.DB, joins map[string]any) error {
+	for table, join := range joins {
+		err := withoutRelations(db).Table(table).AutoMigrate(join)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withoutRelations is db, but AutoMigrate leaves relations alone
+func withoutRelations(db *gorm.DB) *gorm.DB {
+	tx := db.Session(&gorm.Session{})
+	config := *tx.Config
+	config.IgnoreRelationshipsWhenMigrating = true
+	tx.Config = &config
+
+	return tx
+}
+
+// replacedRelations returns the child relations of the entity in ChildrenReplace mode
+func replacedRelations(entity model.Entity) ([]*schema.Relationship, error) {
+	sch, err := model.Schema(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*schema.Relationship, 0)
+
+	for _, relation := range sch.Relationships.Relations {
+		if relation.Type == schema.BelongsTo || model.ChildModeOf(entity, relation.Name) != model.ChildrenReplace {
+			continue
+		}
+
+		result = append(result, relation)
+	}
+
+	return result, nil
+}
+
+// replaceChildren saves the children of the relations in entity (with id), and deletes the ones that are not in it
+func replaceChildren(tx *gorm.DB, key *model.Key, id any, entity any, relations []*schema.Relationship) error {
+	if len(relations) == 0 {
+		return nil
+	}
+
+	value := reflect.ValueOf(entity)
+
+	// the owner of the children, when the body had no id
+	err := key.Set(tx.Statement.Context, entity, id)
+
+	if err != nil {
+		return err
+	}
+
+	for _, relation := range relations {
+		children := relation.Field.ReflectValueOf(tx.Statement.Context, value).Interface()
+		// the owner is only used for its id, children and join tables have names of their own
+		err = tx.Model(entity).Association(relation.Name).Unscoped().Replace(children)
+
+		if err != nil {
+			return err
+		}
+
+		// replace only links existing children, so their changes are saved on their own
+		err = saveChildren(tx.Session(&gorm.Session{FullSaveAssociations: true}), children)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveChildren upserts the children (a slice or a single child), when there are any
+func saveChildren(tx *gorm.DB, children any) error {
+	value := reflect.ValueOf(children)
+
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil
+	}
+
+	if value.Kind() == reflect.Slice && value.Len() == 0 {
+		return nil
+	}
+
+	if value.Kind() == reflect.Struct {
+		if value.IsZero() {
+			return nil
+		}
+
+		// a has one by value, saved through a pointer to a copy
+		pointer := reflect.New(value.Type())
+		pointer.Elem().Set(value)
+		children = pointer.Interface()
+	}
+
+	return tx.Save(children).Error
+}
+
+// patchedChildren moves the relations of a patch (by json or field name) into entity,
+// and returns the relations that were patched
+func patchedChildren(data map[string]any, entity any, relations []*schema.Relationship) ([]*schema.Relationship, error) {
+	result := make([]*schema.Relationship, 0)
+	value := reflect.ValueOf(entity)
+
+	for _, relation := range relations {
+		key := relation.Name
+		children, ok := data[key]
+
+		if !ok {
+			key = jsonName(relation.Field)
+			children, ok = data[key]
+		}
+
+		if !ok {
+			continue
+		}
+
+		delete(data, key)
+
+		// through json, so the children are decoded like the api would
+		bs, err := json.Marshal(children)
+
+		if err != nil {
+			return nil, err
+		}
+
+		it := reflect.New(relation.Field.FieldType)
+		err = json.Unmarshal(bs, it.Interface())
+
+		if err != nil {
+			return nil, err
+		}
+
+		relation.Field.ReflectValueOf(context.Background(), value).Set(it.Elem())
+		result = append(result, relation)
+	}
+
+	return result, nil
+}
+
+func jsonName(field *schema.Field) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+	if name == "" || name == "-" {
+		return field.Name
+	}
+
+	return name
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type (
+	keeper struct {
+		ID    int64     `json:"id"`
+		Title string    `json:"title"`
+		Pets  []*kept   `gorm:"foreignKey:KeeperID" json:"pets"`
+		Tags  []*keyTag `gorm:"many2many:keeper_tags" json:"tags"`
+	}
+
+	kept struct {
+		ID       int64  `json:"id"`
+		Name     string `json:"name"`
+		KeeperID int64  `json:"-"`
+	}
+
+	keyTag struct {
+		ID    int64  `json:"id"`
+		Label string `json:"label"`
+	}
+)
+
+func (keeper) Name() string     { return "keepers" }
+func (keeper) Create() any      { return &keeper{} }
+func (keeper) CreateArray() any { return make([]*keeper, 0) }
+func (keeper) Children() map[string]model.ChildMode {
+	return map[string]model.ChildMode{"Pets": model.ChildrenReplace, "Tags": model.ChildrenReplace}
+}
+
+// database opens an in memory sqlite db with the entities and their children migrated
+func database(t *testing.T, entities ...model.Entity) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one connection, every connection gets an in memory db of its own
+	pool, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetMaxOpenConns(1)
+
+	for _, entity := range entities {
+		err = AutoMigrate(db, entity)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = MigrateChildren(db, entities...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestReplaceChildrenUpdatesExisting(t *testing.T) {
+	ctx := context.Background()
+	db := database(t, keeper{})
+	storer := NewStorer(db, keeper{})
+
+	_, err := storer.Create(ctx, &keeper{Title: "k", Pets: []*kept{{Name: "a"}, {Name: "b"}}, Tags: []*keyTag{{Label: "x"}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storer.Update(ctx, "1", &keeper{Title: "k", Pets: []*kept{{ID: 1, Name: "a2"}}, Tags: []*keyTag{{ID: 1, Label: "x2"}}}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pets := make([]*kept, 0)
+	err = db.Table("kepts").Order("id").Find(&pets).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pets) != 1 || pets[0].Name != "a2" {
+		t.Fatalf("expected only a2 after update, got %v", pets)
+	}
+
+	tag := &keyTag{}
+	err = db.Table("key_tags").First(tag, 1).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tag.Label != "x2" {
+		t.Fatalf("expected the tag to be updated, got %s", tag.Label)
+	}
+
+	_, err = storer.Patch(ctx, "1", map[string]any{"pets": []map[string]any{{"id": 1, "name": "a3"}, {"name": "c"}}}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pets = make([]*kept, 0)
+	err = db.Table("kepts").Order("id").Find(&pets).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pets) != 2 || pets[0].Name != "a3" || pets[1].Name != "c" {
+		t.Fatalf("expected a3 and c after patch, got %v", pets)
+	}
+}
+
+type (
+	// merger has children in the default merge mode
+	merger struct {
+		ID    int64     `json:"id"`
+		Title string    `json:"title"`
+		Pets  []*merged `gorm:"foreignKey:MergerID" json:"pets"`
+	}
+
+	merged struct {
+		ID       int64  `json:"id"`
+		Name     string `json:"name"`
+		MergerID int64  `json:"-"`
+	}
+)
+
+func (merger) Name() string     { return "mergers" }
+func (merger) Create() any      { return &merger{} }
+func (merger) CreateArray() any { return make([]*merger, 0) }
+
+func TestMigrateChildren(t *testing.T) {
+	models := ChildModels(keeper{}, merger{}, pet{})
+	names := make([]string, 0)
+
+	for _, it := range models {
+		names = append(names, reflect.TypeOf(it).Elem().Name())
+	}
+
+	slices.Sort(names)
+
+	if !slices.Equal(names, []string{"kept", "keyTag", "merged"}) {
+		t.Fatalf("expected kept, keyTag and merged, got %v", names)
+	}
+
+	db := database(t, keeper{}, merger{})
+
+	for _, table := range []string{"keepers", "mergers", "kepts", "key_tags", "keeper_tags", "mergeds"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("expected table %s", table)
+		}
+	}
+
+	// the owner is only migrated under its name
+	if db.Migrator().HasTable("keeper") {
+		t.Error("expected no table named after the keeper struct")
+	}
+}
+
+func TestChildModes(t *testing.T) {
+	ctx := context.Background()
+	db := database(t, keeper{}, merger{})
+	keepers := NewStorer(db, keeper{})
+	mergers := NewStorer(db, merger{})
+
+	_, err := keepers.Create(ctx, &keeper{Title: "k", Pets: []*kept{{Name: "a"}, {Name: "b"}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = mergers.Create(ctx, &merger{Title: "m", Pets: []*merged{{Name: "a"}, {Name: "b"}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a patch without the children leaves them be
+	_, err = keepers.Patch(ctx, "1", map[string]any{"title": "k2"}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := make([]string, 0)
+	err = db.Table("kepts").Order("id").Pluck("name", &kept).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(kept, []string{"a", "b"}) {
+		t.Fatalf("expected a and b to be kept, got %v", kept)
+	}
+
+	// merge mode updates the given children, and leaves the missing ones
+	_, err = mergers.Update(ctx, "1", &merger{Title: "m", Pets: []*merged{{ID: 1, Name: "a2"}}}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := make([]string, 0)
+	err = db.Table("mergeds").Order("id").Pluck("name", &merged).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(merged, []string{"a2", "b"}) {
+		t.Fatalf("expected a2 and b after merge, got %v", merged)
+	}
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/Meduzz/quickapi/model"
+)
+
+// NewFileStorer creates a Storer that keeps all entities in memory and writes
+// them to dir/<entity.Name>.json after each change. Existing data is loaded on creation.
+// Every field known to gorm (including relations) is stored by its go name,
+// so fields hidden from json are kept as well.
+func NewFileStorer(dir string, entity model.Entity) (Storer, error) {
+	store := newMemoryStorage(entity)
+	path := filepath.Join(dir, fmt.Sprintf("%s.json", entity.Name()))
+
+	err := loadFile(path, store)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.persist = func(rows map[string]any) error {
+		return writeFile(path, entity, rows)
+	}
+
+	return store, nil
+}
+
+func loadFile(path string, store *memoryStorage) error {
+	bs, err := os.ReadFile(path)
+
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	ctx := context.Background()
+	sch, err := store.schema(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	pk, err := model.KeyOf(store.entity)
+
+	if err != nil {
+		return err
+	}
+
+	rows := make(map[string]map[string]json.RawMessage)
+	err = json.Unmarshal(bs, &rows)
+
+	if err != nil {
+		return err
+	}
+
+	for id, fields := range rows {
+		row := store.entity.Create()
+		value := reflect.ValueOf(row)
+
+		for name, raw := range fields {
+			field := sch.LookUpField(name)
+
+			if field == nil {
+				continue
+			}
+
+			it := reflect.New(field.FieldType)
+			err = json.Unmarshal(raw, it.Interface())
+
+			if err != nil {
+				return fmt.Errorf("reading field %s of %s: %w", name, id, err)
+			}
+
+			field.ReflectValueOf(ctx, value).Set(it.Elem())
+		}
+
+		key, _ := pk.ValueOf(ctx, row)
+		store.bumpSequence(key)
+		store.rows[id] = row
+	}
+
+	return nil
+}
+
+func writeFile(path string, entity model.Entity, rows map[string]any) error {
+	ctx := context.Background()
+	sch, err := model.Schema(entity)
+
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]map[string]any)
+
+	for id, row := range rows {
+		value := reflect.ValueOf(row)
+		fields := make(map[string]any)
+
+		for _, field := range sch.Fields {
+			if !field.Readable {
+				continue
+			}
+
+			fields[field.Name] = field.ReflectValueOf(ctx, value).Interface()
+		}
+
+		data[id] = fields
+	}
+
+	bs, err := json.MarshalIndent(data, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	// write to a temp file and rename, so a crash never leaves half a file behind
+	tmp := fmt.Sprintf("%s.tmp", path)
+	err = os.WriteFile(tmp, bs, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
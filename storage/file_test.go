@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Meduzz/quickapi/model"
+)
+
+// secret has a field hidden from json and a generated key
+type secret struct {
+	Code  string `gorm:"primaryKey" json:"code"`
+	Value string `json:"value"`
+	Hash  string `json:"-"`
+}
+
+func (secret) Name() string                    { return "secrets" }
+func (secret) Create() any                     { return &secret{} }
+func (secret) CreateArray() any                { return make([]*secret, 0) }
+func (secret) Key() (string, model.IDStrategy) { return "Code", model.IDULID }
+
+func TestFileStorerReloads(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storer, err := NewFileStorer(dir, pet{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed(t, storer, &pet{Species: "cat", Age: 3}, &pet{Species: "dog", Age: 5})
+
+	err = storer.Delete(ctx, "1", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFileStorer(dir, pet{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := reloaded.Search(ctx, 0, 10, nil, nil, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pets := found.([]*pet); len(pets) != 1 || pets[0].Species != "dog" {
+		t.Fatalf("expected the dog, got %v", pets)
+	}
+
+	// the sequence continues after the loaded rows
+	created, err := reloaded.Create(ctx, &pet{Species: "bird"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.(*pet).ID != 3 {
+		t.Fatalf("expected id 3, got %d", created.(*pet).ID)
+	}
+}
+
+func TestFileStorerKeepsChanges(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	storer, err := NewFileStorer(dir, secret{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := storer.Create(ctx, &secret{Value: "a", Hash: "h"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := created.(*secret).Code
+
+	_, err = storer.Update(ctx, code, &secret{Value: "b", Hash: "h2"}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storer.Patch(ctx, code, map[string]any{"value": "c"}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewFileStorer(dir, secret{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := reloaded.Read(ctx, code, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if it := read.(*secret); it.Value != "c" || it.Hash != "h2" {
+		t.Fatalf("expected the patched value and the hidden hash, got %+v", it)
+	}
+
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "secrets.json" {
+		t.Fatalf("expected only secrets.json, got %v", entries)
+	}
+}
+
+func TestFileStorerRejectsBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "pets.json"), []byte(`{"1":{"Age":"old"}}`), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewFileStorer(dir, pet{})
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,401 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type (
+	// JsonData is a json document, stored as jsonb in postgres, json in mysql and text elsewhere
+	JsonData string
+
+	// JsonRow is how json entities are stored, the entity itself is kept in Data.
+	JsonRow struct {
+		ID        int64    `gorm:"primaryKey;autoIncrement"`
+		Data      JsonData `gorm:"not null"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+
+	jsonStorage struct {
+		db     *gorm.DB
+		entity model.Entity
+	}
+)
+
+var (
+	_ Storer = (*jsonStorage)(nil)
+
+	jsonPathPattern = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+)
+
+// NewJsonStorer creates a Storer that keeps each entity as a json document,
+// where and sort keys are json paths (ie owner.name) into the document.
+func NewJsonStorer(db *gorm.DB, entity model.Entity) Storer {
+	return &jsonStorage{db, entity}
+}
+
+// MigrationModel returns what should be migrated for the entity
+func MigrationModel(entity model.Entity) any {
+	if model.KindOf(entity) == model.KindJson {
+		return &JsonRow{}
+	}
+
+	return entity.Create()
+}
+
+func (JsonData) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return "jsonb"
+	case "mysql":
+		return "json"
+	default:
+		return "text"
+	}
+}
+
+func (s *jsonStorage) Create(ctx context.Context, entity any) (any, error) {
+	data, err := encodeDocument(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	row := &JsonRow{Data: data}
+	err = s.db.
+		WithContext(ctx).
+		Table(s.entity.Name()).
+		Create(row).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decode(ctx, row)
+}
+
+func (s *jsonStorage) Read(ctx context.Context, id string, preload map[string]string) (any, error) {
+	row := &JsonRow{}
+	err := s.db.
+		WithContext(ctx).
+		Table(s.entity.Name()).
+		Where("id = ?", id).
+		First(row).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, herror.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return s.decode(ctx, row)
+}
+
+func (s *jsonStorage) Update(ctx context.Context, id string, entity any, hooks []model.Hook) (any, error) {
+	data, err := encodeDocument(entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.write(s.db.WithContext(ctx), id, data, hooks)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Read(ctx, id, nil)
+}
+
+func (s *jsonStorage) Delete(ctx context.Context, id string, hooks []model.Hook) error {
+	query := s.db.
+		WithContext(ctx).
+		Table(s.entity.Name()).
+		Where("id = ?", id)
+
+	slice.ForEach(hooks, func(hook model.Hook) {
+		query = query.Scopes(hook)
+	})
+
+	result := query.Delete(&JsonRow{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return herror.ErrConflict
+	}
+
+	return nil
+}
+
+func (s *jsonStorage) Search(ctx context.Context, skip, take int, where map[string]string, order map[string]string, preload map[string]string, hooks []model.Hook) (any, error) {
+	query := s.db.
+		WithContext(ctx).
+		Table(s.entity.Name()).
+		Offset(skip).
+		Limit(take)
+
+	for path, value := range where {
+		expr, err := s.textPath(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		query = query.Where(clause.Eq{Column: expr, Value: value})
+	}
+
+	if len(order) > 0 {
+		paths := make([]string, 0, len(order))
+
+		for path := range order {
+			paths = append(paths, path)
+		}
+
+		sort.Strings(paths)
+		orderBy := clause.Expr{}
+
+		for _, path := range paths {
+			expr, err := s.valuePath(path)
+
+			if err != nil {
+				return nil, err
+			}
+
+			desc, err := isDescending(order[path])
+
+			if err != nil {
+				return nil, err
+			}
+
+			if len(orderBy.SQL) > 0 {
+				orderBy.SQL += ", "
+			}
+
+			orderBy.SQL += expr.SQL
+
+			if desc {
+				orderBy.SQL += " DESC"
+			}
+
+			orderBy.Vars = append(orderBy.Vars, expr.Vars...)
+		}
+
+		query = query.Clauses(clause.OrderBy{Expression: orderBy})
+	}
+
+	slice.ForEach(hooks, func(hook model.Hook) {
+		query = query.Scopes(hook)
+	})
+
+	rows := make([]*JsonRow, 0)
+	err := query.Find(&rows).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := reflect.ValueOf(s.entity.CreateArray())
+
+	for _, row := range rows {
+		entity, err := s.decode(ctx, row)
+
+		if err != nil {
+			return nil, err
+		}
+
+		data = reflect.Append(data, reflect.ValueOf(entity))
+	}
+
+	return data.Interface(), nil
+}
+
+func (s *jsonStorage) Patch(ctx context.Context, id string, data map[string]any, preload map[string]string, hooks []model.Hook) (any, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := &JsonRow{}
+		err := tx.
+			Table(s.entity.Name()).
+			Where("id = ?", id).
+			First(row).Error
+
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return herror.ErrNotFound
+			}
+
+			return err
+		}
+
+		document := make(map[string]any)
+		err = json.Unmarshal([]byte(row.Data), &document)
+
+		if err != nil {
+			return err
+		}
+
+		for key, value := range data {
+			document[key] = value
+		}
+
+		merged, err := encodeDocument(document)
+
+		if err != nil {
+			return err
+		}
+
+		return s.write(tx, id, merged, hooks)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Read(ctx, id, preload)
+}
+
+func (s *jsonStorage) write(db *gorm.DB, id string, data JsonData, hooks []model.Hook) error {
+	query := db.
+		Model(&JsonRow{}).
+		Table(s.entity.Name()).
+		Where("id = ?", id)
+
+	slice.ForEach(hooks, func(hook model.Hook) {
+		query = query.Scopes(hook)
+	})
+
+	result := query.Updates(map[string]any{"data": data, "updated_at": time.Now()})
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return herror.ErrConflict
+	}
+
+	return nil
+}
+
+// decode turns a row into a *T, with the row id set as its key
+func (s *jsonStorage) decode(ctx context.Context, row *JsonRow) (any, error) {
+	entity := s.entity.Create()
+	err := json.Unmarshal([]byte(row.Data), entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := model.KeyOf(s.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = key.Set(ctx, entity, row.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+// valuePath creates an expression for the value at path, typed as the dialect sees fit.
+func (s *jsonStorage) valuePath(path string) (clause.Expr, error) {
+	if !jsonPathPattern.MatchString(path) {
+		return clause.Expr{}, fmt.Errorf("syntax error in json path %s", path)
+	}
+
+	switch s.db.Dialector.Name() {
+	case "sqlite":
+		return clause.Expr{SQL: "json_extract(data, ?)", Vars: []any{fmt.Sprintf("$.%s", path)}}, nil
+	case "postgres":
+		return clause.Expr{SQL: "data #> ?", Vars: []any{postgresPath(path)}}, nil
+	case "mysql":
+		return clause.Expr{SQL: "JSON_EXTRACT(data, ?)", Vars: []any{fmt.Sprintf("$.%s", path)}}, nil
+	default:
+		return clause.Expr{}, fmt.Errorf("json entities are not supported on %s", s.db.Dialector.Name())
+	}
+}
+
+// textPath creates an expression for the value at path as text, booleans are true/false in all dialects.
+func (s *jsonStorage) textPath(path string) (clause.Expr, error) {
+	if !jsonPathPattern.MatchString(path) {
+		return clause.Expr{}, fmt.Errorf("syntax error in json path %s", path)
+	}
+
+	switch s.db.Dialector.Name() {
+	case "sqlite":
+		sqlitePath := fmt.Sprintf("$.%s", path)
+		return clause.Expr{
+			SQL:  "CASE json_type(data, ?) WHEN 'true' THEN 'true' WHEN 'false' THEN 'false' ELSE CAST(json_extract(data, ?) AS TEXT) END",
+			Vars: []any{sqlitePath, sqlitePath},
+		}, nil
+	case "postgres":
+		return clause.Expr{SQL: "data #>> ?", Vars: []any{postgresPath(path)}}, nil
+	case "mysql":
+		return clause.Expr{SQL: "JSON_UNQUOTE(JSON_EXTRACT(data, ?))", Vars: []any{fmt.Sprintf("$.%s", path)}}, nil
+	default:
+		return clause.Expr{}, fmt.Errorf("json entities are not supported on %s", s.db.Dialector.Name())
+	}
+}
+
+// encodeDocument turns the entity into a json document, without the id since that's kept in its own column
+func encodeDocument(entity any) (JsonData, error) {
+	bs, err := json.Marshal(entity)
+
+	if err != nil {
+		return "", err
+	}
+
+	document := make(map[string]any)
+	err = json.Unmarshal(bs, &document)
+
+	if err != nil {
+		return "", err
+	}
+
+	delete(document, "id")
+
+	bs, err = json.Marshal(document)
+
+	if err != nil {
+		return "", err
+	}
+
+	return JsonData(bs), nil
+}
+
+func postgresPath(path string) string {
+	return fmt.Sprintf("{%s}", strings.ReplaceAll(path, ".", ","))
+}
+
+func isDescending(direction string) (bool, error) {
+	switch strings.ToLower(direction) {
+	case "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("syntax error near %s", direction)
+	}
+}
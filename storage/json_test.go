@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+)
+
+type (
+	// document has a nested struct, which gorm would take for a relation
+	document struct {
+		ID    int64  `json:"key"`
+		Title string `json:"title"`
+		Done  bool   `json:"done"`
+		Owner *party `json:"owner,omitempty"`
+	}
+
+	party struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+)
+
+func (document) Name() string           { return "documents" }
+func (document) Create() any            { return &document{} }
+func (document) CreateArray() any       { return make([]*document, 0) }
+func (document) Kind() model.EntityKind { return model.KindJson }
+
+func TestJsonStorer(t *testing.T) {
+	ctx := context.Background()
+	storer := NewJsonStorer(database(t, document{}), document{})
+
+	for _, it := range []*document{
+		{Title: "a", Done: true, Owner: &party{Name: "bob", Age: 40}},
+		{Title: "b", Owner: &party{Name: "alice", Age: 30}},
+		{Title: "c", Done: true, Owner: &party{Name: "alice", Age: 50}},
+	} {
+		created, err := storer.Create(ctx, it)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if created.(*document).ID == 0 {
+			t.Fatal("expected the row id as key")
+		}
+	}
+
+	tests := []struct {
+		name  string
+		where map[string]string
+		sort  map[string]string
+		want  []string // titles, in order
+	}{
+		{"nested where", map[string]string{"owner.name": "alice"}, map[string]string{"title": "asc"}, []string{"b", "c"}},
+		{"nested sort", nil, map[string]string{"owner.age": "desc"}, []string{"c", "a", "b"}},
+		{"true", map[string]string{"done": "true"}, map[string]string{"title": "asc"}, []string{"a", "c"}},
+		{"false", map[string]string{"done": "false"}, nil, []string{"b"}},
+		{"number", map[string]string{"owner.age": "40"}, nil, []string{"a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, err := storer.Search(ctx, 0, 10, test.where, test.sort, nil, nil)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			documents := found.([]*document)
+
+			if len(documents) != len(test.want) {
+				t.Fatalf("expected %v, got %d documents", test.want, len(documents))
+			}
+
+			for i, it := range documents {
+				if it.Title != test.want[i] {
+					t.Fatalf("expected %s at %d, got %s", test.want[i], i, it.Title)
+				}
+			}
+		})
+	}
+
+	_, err := storer.Search(ctx, 0, 10, map[string]string{"owner.name') OR 1=1 --": "x"}, nil, nil, nil)
+
+	if err == nil {
+		t.Fatal("expected a syntax error for the path")
+	}
+}
+
+func TestJsonStorerPatch(t *testing.T) {
+	ctx := context.Background()
+	storer := NewJsonStorer(database(t, document{}), document{})
+
+	_, err := storer.Create(ctx, &document{Title: "a", Owner: &party{Name: "bob", Age: 40}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := storer.Patch(ctx, "1", map[string]any{"done": true}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := patched.(*document)
+
+	if it.ID != 1 || it.Title != "a" || !it.Done || it.Owner == nil || it.Owner.Name != "bob" {
+		t.Fatalf("expected the patch merged into document 1, got %+v", it)
+	}
+
+	// top level fields are replaced, not merged
+	patched, err = storer.Patch(ctx, "1", map[string]any{"owner": map[string]any{"name": "alice"}}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if it := patched.(*document); it.Owner.Name != "alice" || it.Owner.Age != 0 || it.Title != "a" {
+		t.Fatalf("expected a new owner, got %+v", it.Owner)
+	}
+
+	_, err = storer.Patch(ctx, "2", map[string]any{"done": true}, nil, nil)
+
+	if !errors.Is(err, herror.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
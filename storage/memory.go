@@ -0,0 +1,500 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/gorm/schema"
+)
+
+type (
+	// memoryStorage keeps entities in a map, preloads are ignored and gorm hooks (scopes) respond with 501.
+	memoryStorage struct {
+		entity   model.Entity
+		lock     sync.RWMutex
+		rows     map[string]any
+		sequence int64
+		persist  func(map[string]any) error // called with all rows after each change
+	}
+)
+
+var (
+	_ Storer = (*memoryStorage)(nil)
+
+	ErrNoPrimaryKey = errors.New("entity has no primary key")
+)
+
+// NewMemoryStorer creates a Storer that keeps all entities in memory,
+// with the same where/sort/skip/take semantics as the gorm storer.
+func NewMemoryStorer(entity model.Entity) Storer {
+	return newMemoryStorage(entity)
+}
+
+func newMemoryStorage(entity model.Entity) *memoryStorage {
+	return &memoryStorage{
+		entity: entity,
+		rows:   make(map[string]any),
+	}
+}
+
+func (s *memoryStorage) Create(ctx context.Context, entity any) (any, error) {
+	sch, err := s.schema(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value := reflect.ValueOf(entity)
+	pk, err := model.KeyOf(s.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// uuids and ulids are generated, natural keys are required
+	err = pk.Assign(ctx, entity)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", herror.ErrBadRequest, err)
+	}
+
+	key, zero := pk.ValueOf(ctx, entity)
+
+	if zero {
+		if pk.Strategy != model.IDAuto {
+			return nil, herror.ErrBadRequest
+		}
+
+		s.sequence++
+		key = s.sequence
+
+		err = pk.Set(ctx, entity, key)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// rows are kept by id, like the (normalized) ids of paths
+	id := pk.Format(key)
+
+	if _, exists := s.rows[id]; exists {
+		return nil, herror.ErrConflict
+	}
+
+	s.bumpSequence(key)
+	touch(ctx, sch, value, true)
+
+	s.rows[id] = copyOf(entity)
+
+	return entity, s.changed()
+}
+
+func (s *memoryStorage) Read(ctx context.Context, id string, preload map[string]string) (any, error) {
+	_, err := s.schema(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	row, ok := s.rows[id]
+
+	if !ok {
+		return nil, herror.ErrNotFound
+	}
+
+	return copyOf(row), nil
+}
+
+func (s *memoryStorage) Update(ctx context.Context, id string, entity any, hooks []model.Hook) (any, error) {
+	sch, err := s.schema(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = scoped(hooks)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	row, ok := s.rows[id]
+
+	if !ok {
+		return nil, herror.ErrNotFound
+	}
+
+	// like gorm Updates with a struct, only non-zero fields are updated
+	updated := copyOf(row)
+	source := reflect.ValueOf(entity)
+	target := reflect.ValueOf(updated)
+
+	for _, field := range sch.Fields {
+		if field.PrimaryKey || !field.Updatable || !field.Readable {
+			continue
+		}
+
+		value, zero := field.ValueOf(ctx, source)
+
+		if zero {
+			continue
+		}
+
+		err = field.Set(ctx, target, value)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	touch(ctx, sch, target, false)
+	s.rows[id] = updated
+
+	return copyOf(updated), s.changed()
+}
+
+func (s *memoryStorage) Delete(ctx context.Context, id string, hooks []model.Hook) error {
+	_, err := s.schema(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	err = scoped(hooks)
+
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.rows[id]; !ok {
+		return herror.ErrNotFound
+	}
+
+	delete(s.rows, id)
+
+	return s.changed()
+}
+
+func (s *memoryStorage) Search(ctx context.Context, skip, take int, where map[string]string, sorting map[string]string, preload map[string]string, hooks []model.Hook) (any, error) {
+	sch, err := s.schema(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = scoped(hooks)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	rows := make([]reflect.Value, 0)
+
+	for _, row := range s.rows {
+		match, err := matches(ctx, sch, reflect.ValueOf(row), where)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if match {
+			rows = append(rows, reflect.ValueOf(copyOf(row)))
+		}
+	}
+
+	err = sortRows(ctx, sch, rows, sorting)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if skip > len(rows) {
+		skip = len(rows)
+	}
+
+	rows = rows[skip:]
+
+	if take >= 0 && take < len(rows) {
+		rows = rows[:take]
+	}
+
+	data := reflect.ValueOf(s.entity.CreateArray())
+	data = reflect.Append(data, rows...)
+
+	return data.Interface(), nil
+}
+
+func (s *memoryStorage) Patch(ctx context.Context, id string, data map[string]any, preload map[string]string, hooks []model.Hook) (any, error) {
+	sch, err := s.schema(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = scoped(hooks)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	row, ok := s.rows[id]
+
+	if !ok {
+		return nil, herror.ErrNotFound
+	}
+
+	updated := copyOf(row)
+	target := reflect.ValueOf(updated)
+
+	for column, value := range data {
+		field := sch.LookUpField(column)
+
+		if field == nil || field.DBName == "" {
+			return nil, fmt.Errorf("no such column: %s", column)
+		}
+
+		// like Update, the key and read only fields are left as they are
+		if field.PrimaryKey || !field.Updatable {
+			continue
+		}
+
+		err = field.Set(ctx, target, value)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	touch(ctx, sch, target, false)
+	s.rows[id] = updated
+
+	return copyOf(updated), s.changed()
+}
+
+func (s *memoryStorage) schema(ctx context.Context) (*schema.Schema, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	sch, err := model.Schema(s.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sch.PrimaryFields) == 0 {
+		return nil, ErrNoPrimaryKey
+	}
+
+	return sch, nil
+}
+
+// scoped rejects hooks (scopes), they're gorm queries and can't be applied to the rows in memory
+func scoped(hooks []model.Hook) error {
+	if len(hooks) > 0 {
+		return herror.ErrorFromCode(http.StatusNotImplemented)
+	}
+
+	return nil
+}
+
+func (s *memoryStorage) bumpSequence(key any) {
+	value := reflect.ValueOf(key)
+
+	if value.CanInt() && value.Int() > s.sequence {
+		s.sequence = value.Int()
+	} else if value.CanUint() && int64(value.Uint()) > s.sequence {
+		s.sequence = int64(value.Uint())
+	}
+}
+
+func (s *memoryStorage) changed() error {
+	if s.persist == nil {
+		return nil
+	}
+
+	return s.persist(s.rows)
+}
+
+// copyOf makes a shallow copy of a *T
+func copyOf(entity any) any {
+	value := reflect.ValueOf(entity).Elem()
+	it := reflect.New(value.Type())
+	it.Elem().Set(value)
+
+	return it.Interface()
+}
+
+// touch sets the autoCreateTime/autoUpdateTime fields like gorm would
+func touch(ctx context.Context, sch *schema.Schema, value reflect.Value, created bool) {
+	now := time.Now()
+
+	for _, field := range sch.Fields {
+		if field.AutoUpdateTime > 0 {
+			field.Set(ctx, value, now)
+		} else if created && field.AutoCreateTime > 0 {
+			if _, zero := field.ValueOf(ctx, value); zero {
+				field.Set(ctx, value, now)
+			}
+		}
+	}
+}
+
+// matches compares the string value of each column with the where filter
+func matches(ctx context.Context, sch *schema.Schema, row reflect.Value, where map[string]string) (bool, error) {
+	for column, expected := range where {
+		field := sch.LookUpField(column)
+
+		if field == nil || field.DBName == "" {
+			return false, fmt.Errorf("no such column: %s", column)
+		}
+
+		value, _ := field.ValueOf(ctx, row)
+
+		if stringOf(value) != expected {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func sortRows(ctx context.Context, sch *schema.Schema, rows []reflect.Value, sorting map[string]string) error {
+	type order struct {
+		field *schema.Field
+		desc  bool
+	}
+
+	columns := make([]string, 0, len(sorting))
+
+	for column := range sorting {
+		columns = append(columns, column)
+	}
+
+	sort.Strings(columns)
+	orders := make([]*order, 0, len(columns))
+
+	for _, column := range columns {
+		field := sch.LookUpField(column)
+
+		if field == nil || field.DBName == "" {
+			return fmt.Errorf("no such column: %s", column)
+		}
+
+		direction := strings.ToLower(sorting[column])
+
+		if direction != "asc" && direction != "desc" {
+			return fmt.Errorf("syntax error near %s", sorting[column])
+		}
+
+		orders = append(orders, &order{field, direction == "desc"})
+	}
+
+	// default to primary key order
+	for _, field := range sch.PrimaryFields {
+		orders = append(orders, &order{field, false})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, o := range orders {
+			a, _ := o.field.ValueOf(ctx, rows[i])
+			b, _ := o.field.ValueOf(ctx, rows[j])
+			result := compare(a, b)
+
+			if result == 0 {
+				continue
+			}
+
+			if o.desc {
+				return result > 0
+			}
+
+			return result < 0
+		}
+
+		return false
+	})
+
+	return nil
+}
+
+func stringOf(value any) string {
+	it := reflect.ValueOf(value)
+
+	if it.Kind() == reflect.Pointer {
+		if it.IsNil() {
+			return ""
+		}
+
+		value = it.Elem().Interface()
+	}
+
+	return fmt.Sprint(value)
+}
+
+func compare(a, b any) int {
+	av := reflect.Indirect(reflect.ValueOf(a))
+	bv := reflect.Indirect(reflect.ValueOf(b))
+
+	if !av.IsValid() || !bv.IsValid() {
+		return boolCompare(av.IsValid(), bv.IsValid())
+	}
+
+	if at, ok := av.Interface().(time.Time); ok {
+		if bt, ok := bv.Interface().(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+
+	switch {
+	case av.CanInt() && bv.CanInt():
+		return cmp.Compare(av.Int(), bv.Int())
+	case av.CanUint() && bv.CanUint():
+		return cmp.Compare(av.Uint(), bv.Uint())
+	case av.CanFloat() && bv.CanFloat():
+		return cmp.Compare(av.Float(), bv.Float())
+	case av.Kind() == reflect.Bool && bv.Kind() == reflect.Bool:
+		return boolCompare(av.Bool(), bv.Bool())
+	}
+
+	return strings.Compare(stringOf(a), stringOf(b))
+}
+
+func boolCompare(a, b bool) int {
+	if a == b {
+		return 0
+	} else if a {
+		return 1
+	}
+
+	return -1
+}
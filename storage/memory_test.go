@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/gorm"
+)
+
+type pet struct {
+	ID      int64  `json:"id"`
+	Species string `json:"species"`
+	Age     int    `json:"age"`
+}
+
+func (pet) Name() string     { return "pets" }
+func (pet) Create() any      { return &pet{} }
+func (pet) CreateArray() any { return make([]*pet, 0) }
+
+func seed(t *testing.T, storer Storer, pets ...*pet) {
+	t.Helper()
+
+	for _, it := range pets {
+		_, err := storer.Create(context.Background(), it)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryCrud(t *testing.T) {
+	ctx := context.Background()
+	storer := NewMemoryStorer(pet{})
+	seed(t, storer, &pet{Species: "cat", Age: 3}, &pet{Species: "dog", Age: 5}, &pet{Species: "cat", Age: 1})
+
+	found, err := storer.Search(ctx, 0, 10, map[string]string{"species": "cat"}, map[string]string{"age": "asc"}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cats := found.([]*pet)
+
+	if len(cats) != 2 || cats[0].ID != 3 || cats[1].ID != 1 {
+		t.Fatalf("expected cats 3 and 1, got %v", cats)
+	}
+
+	_, err = storer.Update(ctx, "2", &pet{Age: 6}, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storer.Patch(ctx, "2", map[string]any{"species": "wolf"}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := storer.Read(ctx, "2", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if it := read.(*pet); it.Age != 6 || it.Species != "wolf" {
+		t.Fatalf("expected a 6 year old wolf, got %v", it)
+	}
+
+	err = storer.Delete(ctx, "2", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = storer.Read(ctx, "2", nil)
+
+	if !errors.Is(err, herror.ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestMemoryPatchKeepsTheKey(t *testing.T) {
+	ctx := context.Background()
+	storer := NewMemoryStorer(pet{})
+	seed(t, storer, &pet{Species: "cat", Age: 3})
+
+	patched, err := storer.Patch(ctx, "1", map[string]any{"id": 7, "age": 4}, nil, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if it := patched.(*pet); it.ID != 1 || it.Age != 4 {
+		t.Fatalf("expected pet 1 at age 4, got %v", it)
+	}
+
+	_, err = storer.Read(ctx, "7", nil)
+
+	if !errors.Is(err, herror.ErrNotFound) {
+		t.Fatalf("expected no pet 7, got %v", err)
+	}
+
+	read, err := storer.Read(ctx, "1", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if it := read.(*pet); it.ID != 1 || it.Age != 4 {
+		t.Fatalf("expected pet 1 at age 4, got %v", it)
+	}
+}
+
+func TestMemoryMissingRows(t *testing.T) {
+	ctx := context.Background()
+	storer := NewMemoryStorer(pet{})
+
+	_, err := storer.Update(ctx, "1", &pet{Age: 1}, nil)
+
+	if !errors.Is(err, herror.ErrNotFound) {
+		t.Errorf("update: expected not found, got %v", err)
+	}
+
+	_, err = storer.Patch(ctx, "1", map[string]any{"age": 1}, nil, nil)
+
+	if !errors.Is(err, herror.ErrNotFound) {
+		t.Errorf("patch: expected not found, got %v", err)
+	}
+
+	err = storer.Delete(ctx, "1", nil)
+
+	if !errors.Is(err, herror.ErrNotFound) {
+		t.Errorf("delete: expected not found, got %v", err)
+	}
+}
+
+func TestMemoryRejectsScopes(t *testing.T) {
+	ctx := context.Background()
+	storer := NewMemoryStorer(pet{})
+	seed(t, storer, &pet{Species: "cat", Age: 3})
+
+	// ie a compare and set filter, that must not be skipped
+	hooks := []model.Hook{func(db *gorm.DB) *gorm.DB {
+		return db.Where("age = ?", 2)
+	}}
+
+	_, update := storer.Update(ctx, "1", &pet{Age: 4}, hooks)
+	_, patch := storer.Patch(ctx, "1", map[string]any{"age": 4}, nil, hooks)
+	_, search := storer.Search(ctx, 0, 10, nil, nil, nil, hooks)
+	remove := storer.Delete(ctx, "1", hooks)
+
+	for name, err := range map[string]error{"update": update, "patch": patch, "search": search, "delete": remove} {
+		if herror.CodeFromError(err) != http.StatusNotImplemented {
+			t.Errorf("%s: expected 501, got %v", name, err)
+		}
+	}
+
+	read, err := storer.Read(ctx, "1", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if it := read.(*pet); it.Age != 3 {
+		t.Fatalf("expected the pet to be unchanged, got %v", it)
+	}
+}
@@ -1,30 +1,74 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/Meduzz/helper/fp/slice"
 	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/api"
 	"github.com/Meduzz/quickapi/model"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type (
 	normalStorage struct {
 		db     *gorm.DB
 		entity model.Entity
+		expand []*api.Expand
+		match  string
+		index  *searchIndex // shared by the expanded and matching storers
 	}
 )
 
+var (
+	_ Expander   = (*normalStorage)(nil)
+	_ Aggregator = (*normalStorage)(nil)
+	_ Matcher    = (*normalStorage)(nil)
+	_ Faceter    = (*normalStorage)(nil)
+	_ Relational = (*normalStorage)(nil)
+)
+
 func NewStorer(db *gorm.DB, entity model.Entity) Storer {
-	return &normalStorage{db, entity}
+	return &normalStorage{db, entity, nil, "", &searchIndex{}}
+}
+
+// DB is the db the rows are kept in
+func (s *normalStorage) DB() *gorm.DB {
+	return s.db
+}
+
+// Expanded returns a storer that also preloads the expanded relations
+func (s *normalStorage) Expanded(expand []*api.Expand) Storer {
+	return &normalStorage{s.db, s.entity, expand, s.match, s.index}
+}
+
+// Matching returns a storer that only searches rows matching the full text search, see MigrateSearch
+func (s *normalStorage) Matching(match string) Storer {
+	return &normalStorage{s.db, s.entity, s.expand, match, s.index}
 }
 
-func (s *normalStorage) Create(entity any) (any, error) {
-	err := s.db.
+func (s *normalStorage) Create(ctx context.Context, entity any) (any, error) {
+	key, err := model.KeyOf(s.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = key.Assign(ctx, entity)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", herror.ErrBadRequest, err)
+	}
+
+	err = s.db.
+		WithContext(ctx).
 		Table(s.entity.Name()).
 		Create(entity).Error
 
@@ -35,13 +79,19 @@ func (s *normalStorage) Create(entity any) (any, error) {
 	return entity, nil
 }
 
-func (s *normalStorage) Read(id string, preload map[string]string) (any, error) {
+func (s *normalStorage) Read(ctx context.Context, id string, preload map[string]string) (any, error) {
 	entity := s.entity.Create()
+	key, value, err := s.key(id)
+
+	if err != nil {
+		return nil, err
+	}
 
-	query := s.preloadQuery(s.db, preload)
-	err := query.
+	query := s.preloadQuery(s.db.WithContext(ctx), preload)
+	err = query.
 		Table(s.entity.Name()).
-		First(entity, id).Error
+		Where(key.Where(s.entity.Name(), value)).
+		First(entity).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -54,20 +104,48 @@ func (s *normalStorage) Read(id string, preload map[string]string) (any, error)
 	return entity, nil
 }
 
-func (s *normalStorage) Update(id string, entity any, hooks []model.Hook) (any, error) {
-	query := s.db.Session(&gorm.Session{FullSaveAssociations: true})
-	query = query.
-		Model(entity).
-		Table(s.entity.Name()).
-		Where("id = ?", id).
-		Clauses(clause.Returning{})
+func (s *normalStorage) Update(ctx context.Context, id string, entity any, hooks []model.Hook) (any, error) {
+	key, value, err := s.key(id)
 
-	slice.ForEach(hooks, func(hook model.Hook) {
-		query = query.Scopes(hook)
-	})
+	if err != nil {
+		return nil, err
+	}
+
+	relations, err := replacedRelations(s.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Session(&gorm.Session{FullSaveAssociations: true})
+		query = query.
+			Model(entity).
+			Table(s.entity.Name()).
+			Where(key.Where(s.entity.Name(), value)).
+			Clauses(clause.Returning{})
+
+		// replaced children are saved by replaceChildren
+		slice.ForEach(relations, func(relation *schema.Relationship) {
+			query = query.Omit(relation.Name)
+		})
+
+		slice.ForEach(hooks, func(hook model.Hook) {
+			query = query.Scopes(hook)
+		})
+
+		result := query.Updates(entity)
+
+		if result.Error != nil {
+			return result.Error
+		}
 
-	result := query.Updates(entity)
-	err := result.Error
+		if result.RowsAffected == 0 {
+			return herror.ErrConflict
+		}
+
+		return replaceChildren(tx, key, value, entity, relations)
+	})
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -77,16 +155,26 @@ func (s *normalStorage) Update(id string, entity any, hooks []model.Hook) (any,
 		return nil, err
 	}
 
-	if result.RowsAffected == 0 {
-		return nil, herror.ErrConflict
-	}
-
 	return entity, nil
 }
 
-func (s *normalStorage) Delete(id string, hooks []model.Hook) error {
+func (s *normalStorage) Delete(ctx context.Context, id string, hooks []model.Hook) error {
 	entity := s.entity.Create()
+	key, value, err := s.key(id)
+
+	if err != nil {
+		return err
+	}
+
+	// the associations are deleted by the key of entity
+	err = key.Set(ctx, entity, value)
+
+	if err != nil {
+		return err
+	}
+
 	query := s.db.
+		WithContext(ctx).
 		Table(s.entity.Name()).
 		Select(clause.Associations)
 
@@ -94,8 +182,8 @@ func (s *normalStorage) Delete(id string, hooks []model.Hook) error {
 		query = query.Scopes(hook)
 	})
 
-	result := query.Delete(entity, id)
-	err := result.Error
+	result := query.Where(key.Where(s.entity.Name(), value)).Delete(entity)
+	err = result.Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -112,10 +200,11 @@ func (s *normalStorage) Delete(id string, hooks []model.Hook) error {
 	return nil
 }
 
-func (s *normalStorage) Search(skip, take int, where map[string]string, sort map[string]string, preload map[string]string, hooks []model.Hook) (any, error) {
+func (s *normalStorage) Search(ctx context.Context, skip, take int, where map[string]string, sort map[string]string, preload map[string]string, hooks []model.Hook) (any, error) {
 	data := s.entity.CreateArray()
 
 	query := s.db.
+		WithContext(ctx).
 		Table(s.entity.Name()).
 		Offset(skip).
 		Limit(take)
@@ -140,6 +229,15 @@ func (s *normalStorage) Search(skip, take int, where map[string]string, sort map
 		query = query.Scopes(hook)
 	})
 
+	if s.match != "" {
+		var err error
+		query, err = s.matchQuery(query, s.match, len(sort) == 0)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	err := query.Find(&data).Error
 
 	if err != nil {
@@ -149,34 +247,209 @@ func (s *normalStorage) Search(skip, take int, where map[string]string, sort map
 	return data, nil
 }
 
-func (s *normalStorage) Patch(id string, data map[string]any, preload map[string]string, hooks []model.Hook) (any, error) {
+func (s *normalStorage) Patch(ctx context.Context, id string, data map[string]any, preload map[string]string, hooks []model.Hook) (any, error) {
 	entity := s.entity.Create()
+	key, value, err := s.key(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	relations, err := replacedRelations(s.entity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// replaced children are moved from the columns into entity
+	data = maps.Clone(data)
+	relations, err = patchedChildren(data, entity, relations)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.
+			Table(s.entity.Name()).
+			Model(entity).
+			Where(key.Where(s.entity.Name(), value)).
+			Clauses(clause.Returning{})
+
+		slice.ForEach(hooks, func(hook model.Hook) {
+			query = query.Scopes(hook)
+		})
+
+		var result *gorm.DB
+
+		if len(data) > 0 {
+			result = query.Updates(data)
+		} else {
+			// only children were patched, but the hooks still apply
+			result = query.Limit(1).Find(entity)
+		}
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return herror.ErrConflict
+		}
+
+		return replaceChildren(tx, key, value, entity, relations)
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, herror.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+func (s *normalStorage) Aggregate(ctx context.Context, aggregate *api.Aggregate) ([]map[string]any, error) {
+	selects := make([]string, 0)
+	vars := make([]any, 0)
+	groups := slice.Map(aggregate.GroupBy, func(column string) clause.Column {
+		return clause.Column{Name: column}
+	})
+
+	slice.ForEach(groups, func(column clause.Column) {
+		selects = append(selects, "?")
+		vars = append(vars, column)
+	})
+
+	if aggregate.Count {
+		selects = append(selects, "COUNT(*) AS ?")
+		vars = append(vars, clause.Column{Name: "count"})
+	}
+
+	functions := []struct {
+		name    string
+		columns []string
+	}{
+		{"sum", aggregate.Sum},
+		{"avg", aggregate.Avg},
+		{"min", aggregate.Min},
+		{"max", aggregate.Max},
+	}
+
+	for _, function := range functions {
+		slice.ForEach(function.columns, func(column string) {
+			selects = append(selects, fmt.Sprintf("%s(?) AS ?", strings.ToUpper(function.name)))
+			vars = append(vars, clause.Column{Name: column}, clause.Column{Name: fmt.Sprintf("%s_%s", function.name, column)})
+		})
+	}
+
+	if len(selects) == 0 {
+		return nil, fmt.Errorf("nothing to aggregate")
+	}
+
+	// the model applies soft deletes
 	query := s.db.
+		WithContext(ctx).
+		Model(s.entity.Create()).
 		Table(s.entity.Name()).
-		Model(entity).
-		Where("id = ?", id).
-		Clauses(clause.Returning{})
+		Select(strings.Join(selects, ", "), vars...)
 
-	slice.ForEach(hooks, func(hook model.Hook) {
+	if len(aggregate.Where) > 0 {
+		query = query.Where(aggregate.Where)
+	}
+
+	if len(groups) > 0 {
+		query = query.Clauses(clause.GroupBy{Columns: groups})
+		query = query.Clauses(clause.OrderBy{Columns: slice.Map(groups, func(column clause.Column) clause.OrderByColumn {
+			return clause.OrderByColumn{Column: column}
+		})})
+	}
+
+	slice.ForEach(aggregate.Hooks, func(hook model.Hook) {
 		query = query.Scopes(hook)
 	})
 
-	result := query.Updates(data)
-	err := result.Error
+	rows := make([]map[string]any, 0)
+	err := query.Find(&rows).Error
 
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, herror.ErrNotFound
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func (s *normalStorage) Facets(ctx context.Context, facets *api.Facets) (map[string][]*api.Facet, error) {
+	result := make(map[string][]*api.Facet)
+
+	for _, field := range facets.Fields {
+		column := clause.Column{Name: field}
+		count := clause.Column{Name: "count"}
+
+		// the model applies soft deletes
+		query := s.db.
+			WithContext(ctx).
+			Model(s.entity.Create()).
+			Table(s.entity.Name()).
+			Select("? AS ?, COUNT(*) AS ?", column, clause.Column{Name: "value"}, count).
+			Clauses(clause.GroupBy{Columns: []clause.Column{column}}).
+			Order(clause.OrderByColumn{Column: count, Desc: true}).
+			Order(clause.OrderByColumn{Column: column})
+
+		if len(facets.Where) > 0 {
+			query = query.Where(facets.Where)
 		}
 
-		return nil, err
+		if facets.Take > 0 {
+			query = query.Limit(facets.Take)
+		}
+
+		slice.ForEach(facets.Hooks, func(hook model.Hook) {
+			query = query.Scopes(hook)
+		})
+
+		if facets.Query != "" {
+			var err error
+			query, err = s.matchQuery(query, facets.Query, false)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		rows := make([]map[string]any, 0)
+		err := query.Find(&rows).Error
+
+		if err != nil {
+			return nil, err
+		}
+
+		result[field] = slice.Map(rows, func(row map[string]any) *api.Facet {
+			count, _ := row["count"].(int64)
+			return &api.Facet{Value: row["value"], Count: count}
+		})
 	}
 
-	if result.RowsAffected == 0 {
-		return nil, herror.ErrConflict
+	return result, nil
+}
+
+// key parses id by the primary key of the entity
+func (s *normalStorage) key(id string) (*model.Key, any, error) {
+	key, err := model.KeyOf(s.entity)
+
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return entity, nil
+	value, err := key.Parse(id)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", herror.ErrBadRequest, err)
+	}
+
+	return key, value, nil
 }
 
 func (s *normalStorage) preloadQuery(query *gorm.DB, preload map[string]string) *gorm.DB {
@@ -203,5 +476,34 @@ func (s *normalStorage) preloadQuery(query *gorm.DB, preload map[string]string)
 		}
 	}
 
+	slice.ForEach(s.expand, func(expand *api.Expand) {
+		query = query.Preload(expand.Relation, expandScope(expand))
+	})
+
 	return query
 }
+
+// expandScope applies the conditions of an expansion to the preload of its relation
+func expandScope(expand *api.Expand) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if len(expand.Where) > 0 {
+			query = query.Where(expand.Where)
+		}
+
+		columns := slices.Sorted(maps.Keys(expand.Sort))
+
+		slice.ForEach(columns, func(column string) {
+			query = query.Order(clause.OrderByColumn{
+				Column: clause.Column{Name: column},
+				Desc:   strings.EqualFold(expand.Sort[column], "desc"),
+			})
+		})
+
+		// the limit is on all preloaded rows, so it's only per owner when there's one owner
+		if expand.Take > 0 {
+			query = query.Limit(expand.Take)
+		}
+
+		return query
+	}
+}
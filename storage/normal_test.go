@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Meduzz/quickapi/api"
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/gorm"
+)
+
+type sale struct {
+	ID        int64          `json:"id"`
+	Region    string         `json:"region"`
+	Rep       string         `json:"rep"`
+	Amount    int            `json:"amount"`
+	DeletedAt gorm.DeletedAt `json:"-"`
+}
+
+func (sale) Name() string     { return "sales" }
+func (sale) Create() any      { return &sale{} }
+func (sale) CreateArray() any { return make([]*sale, 0) }
+
+// sales stores east/ann 10, east/bob 20, west/ann 5 and a deleted west/bob 100
+func sales(t *testing.T) Storer {
+	t.Helper()
+
+	db := database(t, sale{})
+
+	for _, it := range []*sale{{Region: "east", Rep: "ann", Amount: 10}, {Region: "east", Rep: "bob", Amount: 20}, {Region: "west", Rep: "ann", Amount: 5}, {Region: "west", Rep: "bob", Amount: 100}} {
+		err := db.Table(sale{}.Name()).Create(it).Error
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := db.Table(sale{}.Name()).Delete(&sale{}, 4).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewStorer(db, sale{})
+}
+
+func TestAggregate(t *testing.T) {
+	storer := sales(t).(Aggregator)
+	west := func(db *gorm.DB) *gorm.DB { return db.Where("region = ?", "west") }
+
+	tests := []struct {
+		name      string
+		aggregate *api.Aggregate
+		want      []map[string]any
+	}{
+		{"count", &api.Aggregate{Count: true}, []map[string]any{{"count": int64(3)}}},
+		{"count by group", &api.Aggregate{GroupBy: []string{"region"}, Count: true}, []map[string]any{
+			{"region": "east", "count": int64(2)},
+			{"region": "west", "count": int64(1)},
+		}},
+		{"by two groups", &api.Aggregate{GroupBy: []string{"rep", "region"}, Sum: []string{"amount"}}, []map[string]any{
+			{"rep": "ann", "region": "east", "sum_amount": int64(10)},
+			{"rep": "ann", "region": "west", "sum_amount": int64(5)},
+			{"rep": "bob", "region": "east", "sum_amount": int64(20)},
+		}},
+		{"functions", &api.Aggregate{Sum: []string{"amount"}, Avg: []string{"amount"}, Min: []string{"amount", "rep"}, Max: []string{"amount"}}, []map[string]any{
+			{"sum_amount": int64(35), "avg_amount": float64(35) / 3, "min_amount": int64(5), "min_rep": "ann", "max_amount": int64(20)},
+		}},
+		{"where", &api.Aggregate{Where: map[string]string{"rep": "ann"}, Sum: []string{"amount"}}, []map[string]any{{"sum_amount": int64(15)}}},
+		{"hooks", &api.Aggregate{Count: true, Hooks: []model.Hook{west}}, []map[string]any{{"count": int64(1)}}},
+		{"nothing matches", &api.Aggregate{Where: map[string]string{"rep": "cid"}, GroupBy: []string{"region"}, Count: true}, []map[string]any{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := storer.Aggregate(context.Background(), test.aggregate)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(rows, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, rows)
+			}
+		})
+	}
+}
+
+func TestAggregateQuotesColumns(t *testing.T) {
+	storer := sales(t).(Aggregator)
+
+	tests := []struct {
+		name      string
+		aggregate *api.Aggregate
+	}{
+		{"nothing to aggregate", &api.Aggregate{}},
+		{"group by", &api.Aggregate{GroupBy: []string{"region) FROM sales; --"}, Count: true}},
+		{"sum", &api.Aggregate{Sum: []string{"amount) FROM sales; --"}}},
+		{"alias", &api.Aggregate{Max: []string{"amount\" FROM sales; --"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := storer.Aggregate(context.Background(), test.aggregate)
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFacets(t *testing.T) {
+	storer := sales(t).(Faceter)
+	west := func(db *gorm.DB) *gorm.DB { return db.Where("region = ?", "west") }
+
+	tests := []struct {
+		name   string
+		facets *api.Facets
+		want   map[string][]*api.Facet
+	}{
+		{"by count, then value", &api.Facets{Fields: []string{"rep", "region"}}, map[string][]*api.Facet{
+			"rep":    {{Value: "ann", Count: 2}, {Value: "bob", Count: 1}},
+			"region": {{Value: "east", Count: 2}, {Value: "west", Count: 1}},
+		}},
+		{"numbers", &api.Facets{Fields: []string{"amount"}}, map[string][]*api.Facet{
+			"amount": {{Value: int64(5), Count: 1}, {Value: int64(10), Count: 1}, {Value: int64(20), Count: 1}},
+		}},
+		{"take", &api.Facets{Fields: []string{"rep", "region"}, Take: 1}, map[string][]*api.Facet{
+			"rep":    {{Value: "ann", Count: 2}},
+			"region": {{Value: "east", Count: 2}},
+		}},
+		{"where", &api.Facets{Fields: []string{"rep"}, Where: map[string]string{"region": "east"}}, map[string][]*api.Facet{
+			"rep": {{Value: "ann", Count: 1}, {Value: "bob", Count: 1}},
+		}},
+		{"hooks", &api.Facets{Fields: []string{"rep"}, Hooks: []model.Hook{west}}, map[string][]*api.Facet{
+			"rep": {{Value: "ann", Count: 1}},
+		}},
+		{"nothing matches", &api.Facets{Fields: []string{"rep"}, Where: map[string]string{"rep": "cid"}}, map[string][]*api.Facet{
+			"rep": {},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			facets, err := storer.Facets(context.Background(), test.facets)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(facets, test.want) {
+				t.Fatalf("expected %v, got %v", test.want, facets)
+			}
+		})
+	}
+
+	_, err := storer.Facets(context.Background(), &api.Facets{Fields: []string{"rep\" FROM sales --"}})
+
+	if err == nil {
+		t.Fatal("expected the field to be quoted as a column")
+	}
+}
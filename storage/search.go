@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchVector is the generated tsvector column of searchable entities in postgres
+const SearchVector = "search_vector"
+
+type (
+	// searchIndex tells if the fts table of an entity exists in sqlite,
+	// it's looked up once on the first full text search (ie after the migrations)
+	searchIndex struct {
+		once   sync.Once
+		exists bool
+	}
+)
+
+// MigrateSearch keeps a full text index of the searchable columns of the entity (see model.SearchableSupport).
+// In sqlite it's an fts5 table named <entity>_fts, kept in sync by triggers and recreated when the columns change,
+// it's skipped when sqlite is built without fts5 (-tags sqlite_fts5).
+// In postgres it's a generated tsvector column with a gin index, drop the column to change the columns.
+func MigrateSearch(db *gorm.DB, entity model.Entity) error {
+	columns, err := model.SearchableOf(entity)
+
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+
+	switch db.Dialector.Name() {
+	case "sqlite":
+		return migrateFTS5(db, entity.Name(), columns)
+	case "postgres":
+		return migrateTSVector(db, entity.Name(), columns)
+	default:
+		println("full text search is not supported by", db.Dialector.Name())
+		return nil
+	}
+}
+
+func migrateFTS5(db *gorm.DB, table string, columns []string) error {
+	available, err := fts5Available(db)
+
+	if err != nil {
+		return err
+	}
+
+	if !available {
+		println("full text search of", table, "is skipped, sqlite has no fts5, build with -tags sqlite_fts5")
+		return nil
+	}
+
+	fts := table + "_fts"
+	existing := make([]string, 0)
+	err = db.Raw("SELECT name FROM pragma_table_info(?)", fts).Scan(&existing).Error
+
+	if err != nil {
+		return err
+	}
+
+	if slices.Equal(existing, columns) {
+		return nil
+	}
+
+	quoted := slice.Map(columns, quote)
+	values := func(prefix string) string {
+		return strings.Join(slice.Map(quoted, func(column string) string {
+			return prefix + column
+		}), ", ")
+	}
+
+	insert := fmt.Sprintf(`INSERT INTO "%s"(rowid, %s) VALUES (new.rowid, %s);`, fts, strings.Join(quoted, ", "), values("new."))
+	remove := fmt.Sprintf(`INSERT INTO "%s"("%s", rowid, %s) VALUES ('delete', old.rowid, %s);`, fts, fts, strings.Join(quoted, ", "), values("old."))
+
+	statements := []string{
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS "%s_insert"`, fts),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS "%s_delete"`, fts),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS "%s_update"`, fts),
+		fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, fts),
+		fmt.Sprintf(`CREATE VIRTUAL TABLE "%s" USING fts5(%s, content='%s', content_rowid='rowid')`, fts, strings.Join(quoted, ", "), table),
+		fmt.Sprintf(`CREATE TRIGGER "%s_insert" AFTER INSERT ON "%s" BEGIN %s END`, fts, table, insert),
+		fmt.Sprintf(`CREATE TRIGGER "%s_delete" AFTER DELETE ON "%s" BEGIN %s END`, fts, table, remove),
+		fmt.Sprintf(`CREATE TRIGGER "%s_update" AFTER UPDATE ON "%s" BEGIN %s %s END`, fts, table, remove, insert),
+		// index the existing rows
+		fmt.Sprintf(`INSERT INTO "%s"("%s") VALUES ('rebuild')`, fts, fts),
+	}
+
+	if len(existing) == 0 {
+		// nothing to drop
+		statements = statements[4:]
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			err := tx.Exec(statement).Error
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// fts5Available tells if sqlite was built with fts5
+func fts5Available(db *gorm.DB) (bool, error) {
+	available := false
+	err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available).Error
+
+	return available, err
+}
+
+func migrateTSVector(db *gorm.DB, table string, columns []string) error {
+	if db.Migrator().HasColumn(table, SearchVector) {
+		return nil
+	}
+
+	text := strings.Join(slice.Map(columns, func(column string) string {
+		return fmt.Sprintf("coalesce(%s::text, '')", quote(column))
+	}), " || ' ' || ")
+
+	err := db.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" tsvector GENERATED ALWAYS AS (to_tsvector('simple', %s)) STORED`, table, SearchVector, text)).Error
+
+	if err != nil {
+		return err
+	}
+
+	return db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s_%s" ON "%s" USING GIN ("%s")`, table, SearchVector, table, SearchVector)).Error
+}
+
+// matchQuery limits query to the rows matching the full text search, ordered by rank when ranked.
+// Every word of the search must match the start of a word in the searchable columns.
+func (s *normalStorage) matchQuery(query *gorm.DB, match string, ranked bool) (*gorm.DB, error) {
+	table := s.entity.Name()
+	words := strings.Fields(match)
+
+	switch query.Dialector.Name() {
+	case "sqlite":
+		// there's no index when sqlite has no fts5
+		if !s.index.lookup(s.db, table) {
+			return nil, herror.ErrorFromCode(http.StatusNotImplemented)
+		}
+
+		terms := slice.Map(words, func(word string) string {
+			return fmt.Sprintf(`"%s"*`, strings.ReplaceAll(word, `"`, `""`))
+		})
+
+		query = query.Joins(
+			fmt.Sprintf(`JOIN (SELECT rowid AS quickapi_rowid, rank AS quickapi_rank FROM "%s_fts" WHERE "%s_fts" MATCH ?) AS quickapi_search ON quickapi_search.quickapi_rowid = "%s".rowid`, table, table, table),
+			strings.Join(terms, " "),
+		)
+
+		if ranked {
+			query = query.Order("quickapi_search.quickapi_rank")
+		}
+
+		return query, nil
+	case "postgres":
+		terms := slice.Filter(slice.Map(words, func(word string) string {
+			return strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) {
+					return r
+				}
+
+				return -1
+			}, word)
+		}), func(word string) bool {
+			return word != ""
+		})
+
+		tsquery := strings.Join(slice.Map(terms, func(term string) string {
+			return term + ":*"
+		}), " & ")
+
+		vector := fmt.Sprintf(`"%s"."%s"`, table, SearchVector)
+		query = query.Where(fmt.Sprintf("%s @@ to_tsquery('simple', ?)", vector), tsquery)
+
+		if ranked {
+			query = query.Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:  fmt.Sprintf("ts_rank(%s, to_tsquery('simple', ?)) DESC", vector),
+				Vars: []any{tsquery},
+			}})
+		}
+
+		return query, nil
+	default:
+		return nil, herror.ErrorFromCode(http.StatusNotImplemented)
+	}
+}
+
+// lookup checks if the fts table of table exists, the first time it's called
+func (i *searchIndex) lookup(db *gorm.DB, table string) bool {
+	i.once.Do(func() {
+		i.exists = db.Migrator().HasTable(table + "_fts")
+	})
+
+	return i.exists
+}
+
+func quote(column string) string {
+	return fmt.Sprintf(`"%s"`, column)
+}
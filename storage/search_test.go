@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Meduzz/helper/http/herror"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID   int64  `json:"id"`
+	Text string `json:"text" quickapi:"searchable"`
+}
+
+func (note) Name() string     { return "notes" }
+func (note) Create() any      { return &note{} }
+func (note) CreateArray() any { return make([]*note, 0) }
+
+// the index needs -tags sqlite_fts5, without it migrate skips it and search is a 501
+func TestMigrateSearch(t *testing.T) {
+	ctx := context.Background()
+	db := database(t, note{})
+	storer := NewStorer(db, note{})
+
+	for _, text := range []string{"the quick fox", "a lazy dog", "quicker still"} {
+		_, err := storer.Create(ctx, &note{Text: text})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	available, err := fts5Available(db)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := storer.(Matcher).Matching("quick").Search(ctx, 0, 10, nil, nil, nil, nil)
+
+	if !available {
+		if herror.CodeFromError(err) != http.StatusNotImplemented {
+			t.Fatalf("expected 501 without fts5, got %v", err)
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if notes := found.([]*note); len(notes) != 2 {
+		t.Fatalf("expected 2 notes, got %d", len(notes))
+	}
+}
+
+func TestSearchIndexIsLookedUpOnce(t *testing.T) {
+	ctx := context.Background()
+	db := database(t, note{})
+	storer := NewStorer(db, note{})
+	lookups := 0
+
+	err := db.Callback().Row().Before("gorm:row").Register("count_lookups", func(tx *gorm.DB) {
+		if strings.Contains(tx.Statement.SQL.String(), "sqlite_master") {
+			lookups++
+		}
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		// a 501 without fts5
+		storer.(Matcher).Matching("quick").Search(ctx, 0, 10, nil, nil, nil, nil)
+	}
+
+	if lookups != 1 {
+		t.Fatalf("expected the index to be looked up once, got %d", lookups)
+	}
+}
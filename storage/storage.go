@@ -1,6 +1,11 @@
 package storage
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/Meduzz/helper/http/herror"
 	"github.com/Meduzz/quickapi/api"
 	"github.com/Meduzz/quickapi/model"
 	"gorm.io/gorm"
@@ -8,23 +13,54 @@ import (
 
 type (
 	Storer interface {
-		Create(any) (any, error)
-		Read(string, map[string]string) (any, error)
-		Update(string, any, []model.Hook) (any, error)
-		Delete(string, []model.Hook) error
-		Search(int, int, map[string]string, map[string]string, map[string]string, []model.Hook) (any, error)
-		Patch(string, map[string]any, map[string]string, []model.Hook) (any, error)
+		Create(context.Context, any) (any, error)
+		Read(context.Context, string, map[string]string) (any, error)
+		Update(context.Context, string, any, []model.Hook) (any, error)
+		Delete(context.Context, string, []model.Hook) error
+		Search(context.Context, int, int, map[string]string, map[string]string, map[string]string, []model.Hook) (any, error)
+		Patch(context.Context, string, map[string]any, map[string]string, []model.Hook) (any, error)
+	}
+
+	// Expander is a Storer that can expand relations (see api.Expand) on read and search,
+	// expansions are ignored by other storers
+	Expander interface {
+		Expanded([]*api.Expand) Storer
+	}
+
+	// Matcher is a Storer that can do full text search (see api.Search), other storers respond with 501
+	Matcher interface {
+		Matching(string) Storer
+	}
+
+	// Aggregator is a Storer that can aggregate (see api.Aggregate), other storers respond with 501
+	Aggregator interface {
+		Aggregate(context.Context, *api.Aggregate) ([]map[string]any, error)
+	}
+
+	// Faceter is a Storer that can count distinct values (see api.Facets), other storers respond with 501
+	Faceter interface {
+		Facets(context.Context, *api.Facets) (map[string][]*api.Facet, error)
+	}
+
+	// Relational is a Storer kept in a gorm db, the children of its relations are served straight from the db (see DB)
+	Relational interface {
+		DB() *gorm.DB
 	}
 
 	Storage interface {
-		Create(*api.Create) (any, error)
-		Read(*api.Read) (any, error)
-		Update(*api.Update) (any, error)
-		Delete(*api.Delete) error
-		Search(*api.Search) (any, error)
-		Patch(*api.Patch) (any, error)
+		Create(context.Context, *api.Create) (any, error)
+		Read(context.Context, *api.Read) (any, error)
+		Update(context.Context, *api.Update) (any, error)
+		Delete(context.Context, *api.Delete) error
+		Search(context.Context, *api.Search) (any, error)
+		Patch(context.Context, *api.Patch) (any, error)
+		Aggregate(context.Context, *api.Aggregate) (any, error)
+		Facets(context.Context, *api.Facets) (any, error)
 	}
 
+	// Factory creates the Storage of an entity, db is nil unless provided to http.For.
+	Factory func(*gorm.DB, model.Entity) (Storage, error)
+
 	genericStorage struct {
 		storer Storer
 	}
@@ -37,29 +73,128 @@ var (
 func CreateStorage(db *gorm.DB, entity model.Entity) Storage {
 	storer := NewStorer(db, entity)
 
+	if model.KindOf(entity) == model.KindJson {
+		storer = NewJsonStorer(db, entity)
+	}
+
 	return &genericStorage{storer}
 }
 
-func (gs *genericStorage) Create(create *api.Create) (any, error) {
-	return gs.storer.Create(create.Entity)
+// NewStorage wraps any Storer into a Storage.
+func NewStorage(storer Storer) Storage {
+	return &genericStorage{storer}
 }
 
-func (gs *genericStorage) Read(read *api.Read) (any, error) {
-	return gs.storer.Read(read.ID, read.Preload)
+// GormFactory creates the default gorm backed Storage.
+func GormFactory(db *gorm.DB, entity model.Entity) (Storage, error) {
+	if db == nil {
+		return nil, fmt.Errorf("storage for %s requires a db", entity.Name())
+	}
+
+	return CreateStorage(db, entity), nil
+}
+
+// MemoryFactory creates an in memory Storage, useful for tests and prototypes.
+func MemoryFactory(db *gorm.DB, entity model.Entity) (Storage, error) {
+	return NewStorage(NewMemoryStorer(entity)), nil
 }
 
-func (gs *genericStorage) Update(update *api.Update) (any, error) {
-	return gs.storer.Update(update.ID, update.Entity, update.Hooks)
+// FileFactory creates Storages that keep each entity in a json file in dir.
+func FileFactory(dir string) Factory {
+	return func(db *gorm.DB, entity model.Entity) (Storage, error) {
+		storer, err := NewFileStorer(dir, entity)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return NewStorage(storer), nil
+	}
 }
 
-func (gs *genericStorage) Delete(delete *api.Delete) error {
-	return gs.storer.Delete(delete.ID, delete.Hooks)
+// DB is the gorm db of a Relational storage (ie from GormFactory), nil for other storages
+func DB(storage Storage) *gorm.DB {
+	relational, ok := storage.(Relational)
+
+	if !ok {
+		generic, isGeneric := storage.(*genericStorage)
+
+		if !isGeneric {
+			return nil
+		}
+
+		relational, ok = generic.storer.(Relational)
+	}
+
+	if !ok {
+		return nil
+	}
+
+	return relational.DB()
 }
 
-func (gs *genericStorage) Search(search *api.Search) (any, error) {
-	return gs.storer.Search(search.Skip, search.Take, search.Where, search.Sort, search.Preload, search.Hooks)
+func (gs *genericStorage) Create(ctx context.Context, create *api.Create) (any, error) {
+	return gs.storer.Create(ctx, create.Entity)
 }
 
-func (gs *genericStorage) Patch(patch *api.Patch) (any, error) {
-	return gs.storer.Patch(patch.ID, patch.Data, patch.Preload, patch.Hooks)
+func (gs *genericStorage) Read(ctx context.Context, read *api.Read) (any, error) {
+	return gs.expanded(read.Expand).Read(ctx, read.ID, read.Preload)
+}
+
+func (gs *genericStorage) Update(ctx context.Context, update *api.Update) (any, error) {
+	return gs.storer.Update(ctx, update.ID, update.Entity, update.Hooks)
+}
+
+func (gs *genericStorage) Delete(ctx context.Context, delete *api.Delete) error {
+	return gs.storer.Delete(ctx, delete.ID, delete.Hooks)
+}
+
+func (gs *genericStorage) Search(ctx context.Context, search *api.Search) (any, error) {
+	storer := gs.expanded(search.Expand)
+
+	if search.Query != "" {
+		matcher, ok := storer.(Matcher)
+
+		if !ok {
+			return nil, herror.ErrorFromCode(http.StatusNotImplemented)
+		}
+
+		storer = matcher.Matching(search.Query)
+	}
+
+	return storer.Search(ctx, search.Skip, search.Take, search.Where, search.Sort, search.Preload, search.Hooks)
+}
+
+func (gs *genericStorage) Patch(ctx context.Context, patch *api.Patch) (any, error) {
+	return gs.storer.Patch(ctx, patch.ID, patch.Data, patch.Preload, patch.Hooks)
+}
+
+func (gs *genericStorage) Aggregate(ctx context.Context, aggregate *api.Aggregate) (any, error) {
+	aggregator, ok := gs.storer.(Aggregator)
+
+	if !ok {
+		return nil, herror.ErrorFromCode(http.StatusNotImplemented)
+	}
+
+	return aggregator.Aggregate(ctx, aggregate)
+}
+
+func (gs *genericStorage) Facets(ctx context.Context, facets *api.Facets) (any, error) {
+	faceter, ok := gs.storer.(Faceter)
+
+	if !ok {
+		return nil, herror.ErrorFromCode(http.StatusNotImplemented)
+	}
+
+	return faceter.Facets(ctx, facets)
+}
+
+func (gs *genericStorage) expanded(expand []*api.Expand) Storer {
+	expander, ok := gs.storer.(Expander)
+
+	if !ok || len(expand) == 0 {
+		return gs.storer
+	}
+
+	return expander.Expanded(expand)
 }
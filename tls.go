@@ -0,0 +1,131 @@
+package quickapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+type (
+	// TLS settings, the cert and key are reloaded when they change on disk
+	TLS struct {
+		Cert     string `json:"cert" yaml:"cert" toml:"cert"`
+		Key      string `json:"key" yaml:"key" toml:"key"`
+		ClientCA string `json:"client_ca" yaml:"client_ca" toml:"client_ca"` // requires client certs signed by this CA (mTLS)
+		HTTP3    bool   `json:"http3" yaml:"http3" toml:"http3"`             // also serve HTTP/3 over udp on the same address
+	}
+
+	// certificate is a cert and key pair, reloaded when either file is modified
+	certificate struct {
+		cert     string
+		key      string
+		lock     sync.Mutex
+		current  *tls.Certificate
+		modified time.Time
+	}
+)
+
+// enabled tells if there's a cert and key to serve with
+func (t *TLS) enabled() bool {
+	return t != nil && t.Cert != "" && t.Key != ""
+}
+
+// Config creates the tls config of the settings
+func (t *TLS) Config() (*tls.Config, error) {
+	cert := &certificate{cert: t.Cert, key: t.Key}
+
+	// fail on start, rather than on the first handshake
+	_, err := cert.get()
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.get()
+		},
+	}
+
+	if t.ClientCA != "" {
+		bs, err := os.ReadFile(t.ClientCA)
+
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("no certificates in %s", t.ClientCA)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// get returns the current cert, reloading it when the cert or key was modified since last time
+func (c *certificate) get() (*tls.Certificate, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	modified, err := c.lastModified()
+
+	if err != nil {
+		if c.current != nil {
+			println("checking certificate threw error", err.Error())
+			return c.current, nil
+		}
+
+		return nil, err
+	}
+
+	if c.current != nil && !modified.After(c.modified) {
+		return c.current, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.cert, c.key)
+
+	if err != nil {
+		if c.current != nil {
+			// ie the cert was written but not yet the key, keep the old one until both match
+			println("reloading certificate threw error", err.Error())
+			return c.current, nil
+		}
+
+		return nil, err
+	}
+
+	c.current = &cert
+	c.modified = modified
+
+	return c.current, nil
+}
+
+// lastModified is the latest modification time of the cert and key
+func (c *certificate) lastModified() (time.Time, error) {
+	cert, err := os.Stat(c.cert)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	key, err := os.Stat(c.key)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if key.ModTime().After(cert.ModTime()) {
+		return key.ModTime(), nil
+	}
+
+	return cert.ModTime(), nil
+}
@@ -0,0 +1,321 @@
+package quickapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Meduzz/quickapi/http"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// authority signs the certs of the tests
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &authority{cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// sign creates a cert and key for the subject, as pem
+func (a *authority) sign(t *testing.T, subject pkix.Name, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// writeAt writes the file and moves its modification time to at, so reloads don't depend on the resolution of the fs
+func writeAt(t *testing.T, file string, content []byte, at time.Time) {
+	t.Helper()
+
+	err := os.WriteFile(file, content, 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chtimes(file, at, at)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func subjectOf(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed.Subject.CommonName
+}
+
+func TestCertificateReload(t *testing.T) {
+	ca := newAuthority(t)
+	dir := t.TempDir()
+	settings := &TLS{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+	start := time.Now().Add(-time.Hour)
+
+	cert, key := ca.sign(t, pkix.Name{CommonName: "first"}, x509.ExtKeyUsageServerAuth)
+	writeAt(t, settings.Cert, cert, start)
+	writeAt(t, settings.Key, key, start)
+
+	config, err := settings.Config()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name   string
+		change func()
+		want   string
+	}{
+		{"unchanged", func() {}, "first"},
+		{"replaced", func() {
+			cert, key := ca.sign(t, pkix.Name{CommonName: "second"}, x509.ExtKeyUsageServerAuth)
+			writeAt(t, settings.Cert, cert, start.Add(time.Minute))
+			writeAt(t, settings.Key, key, start.Add(time.Minute))
+		}, "second"},
+		{"cert without its key", func() {
+			cert, _ := ca.sign(t, pkix.Name{CommonName: "third"}, x509.ExtKeyUsageServerAuth)
+			writeAt(t, settings.Cert, cert, start.Add(2*time.Minute))
+		}, "second"},
+		{"removed", func() {
+			os.Remove(settings.Key)
+		}, "second"},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			step.change()
+
+			current, err := config.GetCertificate(&tls.ClientHelloInfo{})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := subjectOf(t, current); got != step.want {
+				t.Fatalf("expected %s, got %s", step.want, got)
+			}
+		})
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	ca := newAuthority(t)
+	dir := t.TempDir()
+	cert, key := ca.sign(t, pkix.Name{CommonName: "server"}, x509.ExtKeyUsageServerAuth)
+	files := map[string][]byte{"cert.pem": cert, "key.pem": key, "empty.pem": []byte("nothing here")}
+
+	for name, content := range files {
+		writeAt(t, filepath.Join(dir, name), content, time.Now())
+	}
+
+	tests := []struct {
+		name     string
+		settings *TLS
+	}{
+		{"missing cert", &TLS{Cert: filepath.Join(dir, "missing.pem"), Key: filepath.Join(dir, "key.pem")}},
+		{"key of another cert", &TLS{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "empty.pem")}},
+		{"missing client ca", &TLS{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem"), ClientCA: filepath.Join(dir, "missing.pem")}},
+		{"client ca without certs", &TLS{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem"), ClientCA: filepath.Join(dir, "empty.pem")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.settings.Config()
+
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestClientCertPrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ca := newAuthority(t)
+	dir := t.TempDir()
+	serverCert, serverKey := ca.sign(t, pkix.Name{CommonName: "server"}, x509.ExtKeyUsageServerAuth)
+	files := map[string][]byte{"cert.pem": serverCert, "key.pem": serverKey, "ca.pem": ca.pem}
+
+	for name, content := range files {
+		writeAt(t, filepath.Join(dir, name), content, time.Now())
+	}
+
+	settings := DefaultSettings()
+	settings.TLS = &TLS{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem"), ClientCA: filepath.Join(dir, "ca.pem")}
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := NewStarter(db, thing{}).Engine(settings)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine.GET("/whoami", func(ctx *gin.Context) {
+		ctx.String(nethttp.StatusOK, ctx.GetString(http.PRINCIPAL))
+	})
+
+	config, err := settings.TLS.Config()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &nethttp.Server{Handler: engine}
+	go server.Serve(listener)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	clientCert, clientKey := ca.sign(t, pkix.Name{CommonName: "orders", Organization: []string{"acme"}}, x509.ExtKeyUsageClientAuth)
+	pair, err := tls.X509KeyPair(clientCert, clientKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		certs []tls.Certificate
+		want  string // empty when the handshake fails
+	}{
+		{"with a client cert", []tls.Certificate{pair}, "CN=orders,O=acme"},
+		{"without a client cert", nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &nethttp.Client{Transport: &nethttp.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: test.certs},
+			}}
+
+			res, err := client.Get("https://" + listener.Addr().String() + "/whoami")
+
+			if test.want == "" {
+				if err == nil {
+					res.Body.Close()
+					t.Fatal("expected the handshake to fail")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer res.Body.Close()
+
+			bs, err := io.ReadAll(res.Body)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(bs) != test.want {
+				t.Fatalf("expected %s, got %s", test.want, bs)
+			}
+		})
+	}
+}
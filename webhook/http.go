@@ -0,0 +1,28 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes sets up the manual redelivery endpoint (POST /webhook_deliveries/:id/redeliver) in the provided router group.
+// Subscriptions and the delivery log are served by passing Subscription{} and Delivery{} to http.For like any other entity.
+func (w *Webhooks) Routes(e *gin.RouterGroup) {
+	e.POST(fmt.Sprintf("/%s/:id/redeliver", Delivery{}.Name()), w.redeliver)
+}
+
+func (w *Webhooks) redeliver(ctx *gin.Context) {
+	delivery, err := w.Redeliver(ctx.Request.Context(), ctx.Param("id"))
+
+	if err != nil {
+		println("redelivering webhook threw error", err.Error())
+		code := herror.CodeFromError(err)
+
+		ctx.AbortWithStatus(code)
+		return
+	}
+
+	ctx.JSON(200, delivery)
+}
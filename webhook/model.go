@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/quickapi/model"
+)
+
+type (
+	// Subscription is a registered receiver of change events
+	Subscription struct {
+		ID        int64     `gorm:"autoIncrement" json:"id,omitempty"`
+		URL       string    `json:"url" binding:"required,url"`
+		Secret    string    `json:"secret,omitempty"`                          // used to sign deliveries, write only
+		Events    []string  `gorm:"serializer:json" json:"events,omitempty"`   // operations to deliver, empty means all
+		Entities  []string  `gorm:"serializer:json" json:"entities,omitempty"` // entity names to deliver, empty means all
+		Disabled  bool      `json:"disabled"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// Delivery is the log of an event being delivered to a subscription
+	Delivery struct {
+		ID             int64      `gorm:"autoIncrement" json:"id,omitempty"`
+		SubscriptionID int64      `gorm:"index" json:"subscription_id"`
+		Entity         string     `gorm:"size:64" json:"entity"`
+		Operation      string     `gorm:"size:16" json:"operation"`
+		Payload        string     `json:"payload"`
+		Status         string     `gorm:"size:16;index" json:"status"`
+		Attempts       int        `json:"attempts"`
+		ResponseCode   int        `json:"response_code,omitempty"`
+		LastError      string     `json:"last_error,omitempty"`
+		NextAttempt    *time.Time `json:"next_attempt,omitempty"`
+		CreatedAt      time.Time  `json:"created_at"`
+		UpdatedAt      time.Time  `json:"updated_at"`
+	}
+)
+
+const (
+	StatusPending    = "pending"
+	StatusDelivering = "delivering" // claimed by an instance, retried when it's not done in time
+	StatusDelivered  = "delivered"
+	StatusFailed     = "failed" // out of attempts, or the subscription is gone
+)
+
+var (
+	_ model.Entity = Subscription{}
+	_ model.Entity = Delivery{}
+)
+
+func (Subscription) Name() string {
+	return "webhook_subscriptions"
+}
+
+func (Subscription) Create() any {
+	return &Subscription{}
+}
+
+func (Subscription) CreateArray() any {
+	return make([]*Subscription, 0)
+}
+
+// MarshalJSON leaves the secret out of responses (and event payloads), it can only be written
+func (s Subscription) MarshalJSON() ([]byte, error) {
+	type subscription Subscription
+	it := subscription(s)
+	it.Secret = ""
+
+	return json.Marshal(it)
+}
+
+// Matches returns true if the subscription wants the event
+func (s *Subscription) Matches(event *model.Event) bool {
+	if s.Disabled {
+		return false
+	}
+
+	if len(s.Events) > 0 && !slice.Contains(s.Events, string(event.Operation)) {
+		return false
+	}
+
+	if len(s.Entities) > 0 && !slice.Contains(s.Entities, event.Entity) {
+		return false
+	}
+
+	return true
+}
+
+func (Delivery) Name() string {
+	return "webhook_deliveries"
+}
+
+func (Delivery) Create() any {
+	return &Delivery{}
+}
+
+func (Delivery) CreateArray() any {
+	return make([]*Delivery, 0)
+}
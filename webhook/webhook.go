@@ -0,0 +1,382 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Meduzz/helper/fp/slice"
+	"github.com/Meduzz/helper/hmac"
+	"github.com/Meduzz/helper/http/herror"
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/gorm"
+)
+
+type (
+	// Webhooks turns events into signed deliveries to all matching subscriptions
+	Webhooks struct {
+		db          *gorm.DB
+		client      *http.Client
+		maxAttempts int
+		backoff     time.Duration
+		interval    time.Duration
+		wakeup      chan struct{}
+	}
+
+	Option func(*Webhooks)
+)
+
+const (
+	HeaderEvent     = "X-Quickapi-Event"
+	HeaderDelivery  = "X-Quickapi-Delivery"
+	HeaderSignature = "X-Quickapi-Signature"
+)
+
+const (
+	// maxBackoff is the longest wait between two attempts of a delivery
+	maxBackoff = time.Hour
+	// claimTimeout is how long a claimed delivery is left alone, after that it's retried (ie the instance died)
+	claimTimeout = 5 * time.Minute
+)
+
+// New creates a new webhook dispatcher, deliveries are stored in db.
+func New(db *gorm.DB, options ...Option) *Webhooks {
+	w := &Webhooks{
+		db:          db,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 8,
+		backoff:     time.Second,
+		interval:    5 * time.Second,
+		wakeup:      make(chan struct{}, 1),
+	}
+
+	slice.ForEach(options, func(option Option) {
+		option(w)
+	})
+
+	return w
+}
+
+// WithClient sets the http client used for deliveries
+func WithClient(client *http.Client) Option {
+	return func(w *Webhooks) {
+		w.client = client
+	}
+}
+
+// WithRetries sets the max number of attempts and the initial backoff, which doubles on each failed attempt
+func WithRetries(maxAttempts int, backoff time.Duration) Option {
+	return func(w *Webhooks) {
+		w.maxAttempts = maxAttempts
+		w.backoff = backoff
+	}
+}
+
+// WithInterval sets how often the dispatcher looks for deliveries that are due
+func WithInterval(interval time.Duration) Option {
+	return func(w *Webhooks) {
+		w.interval = interval
+	}
+}
+
+// Sign creates the signature of body that is sent in the X-Quickapi-Signature header
+func Sign(secret string, body []byte) string {
+	return fmt.Sprintf("sha256=%s", hmac.Sign([]byte(secret), body, sha256.New))
+}
+
+// Listener returns a listener to be used with http.WithEventListener
+func (w *Webhooks) Listener() func(context.Context, *model.Event) {
+	return func(ctx context.Context, event *model.Event) {
+		err := w.Publish(ctx, event)
+
+		if err != nil {
+			println("publishing webhook event threw error", err.Error())
+		}
+	}
+}
+
+// Publish logs a pending delivery of event for each matching subscription
+func (w *Webhooks) Publish(ctx context.Context, event *model.Event) error {
+	// changes to the webhooks themselves are never delivered
+	if event.Entity == (Subscription{}).Name() || event.Entity == (Delivery{}).Name() {
+		return nil
+	}
+
+	subscriptions := make([]*Subscription, 0)
+	err := w.db.
+		WithContext(ctx).
+		Table(Subscription{}.Name()).
+		Where("disabled = ?", false).
+		Find(&subscriptions).Error
+
+	if err != nil {
+		return err
+	}
+
+	subscriptions = slice.Filter(subscriptions, func(s *Subscription) bool {
+		return s.Matches(event)
+	})
+
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := slice.Map(subscriptions, func(s *Subscription) *Delivery {
+		return &Delivery{
+			SubscriptionID: s.ID,
+			Entity:         event.Entity,
+			Operation:      string(event.Operation),
+			Payload:        string(payload),
+			Status:         StatusPending,
+			NextAttempt:    &now,
+		}
+	})
+
+	err = w.db.
+		WithContext(ctx).
+		Table(Delivery{}.Name()).
+		Create(&deliveries).Error
+
+	if err != nil {
+		return err
+	}
+
+	w.wake()
+
+	return nil
+}
+
+// Redeliver resets a delivery so that it's delivered again
+func (w *Webhooks) Redeliver(ctx context.Context, id string) (*Delivery, error) {
+	delivery := &Delivery{}
+	err := w.db.
+		WithContext(ctx).
+		Table(Delivery{}.Name()).
+		Where("id = ?", id).
+		First(delivery).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, herror.ErrNotFound
+		}
+
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery.Status = StatusPending
+	delivery.Attempts = 0
+	delivery.NextAttempt = &now
+
+	err = w.db.
+		WithContext(ctx).
+		Table(Delivery{}.Name()).
+		Save(delivery).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	w.wake()
+
+	return delivery, nil
+}
+
+// Run delivers pending deliveries until ctx is cancelled
+func (w *Webhooks) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		err := w.deliverDue(ctx)
+
+		if err != nil {
+			println("delivering webhooks threw error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-w.wakeup:
+		}
+	}
+}
+
+func (w *Webhooks) wake() {
+	select {
+	case w.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Webhooks) deliverDue(ctx context.Context) error {
+	deliveries := make([]*Delivery, 0)
+	err := w.db.
+		WithContext(ctx).
+		Table(Delivery{}.Name()).
+		Where("status IN ? AND next_attempt <= ?", []string{StatusPending, StatusDelivering}, time.Now()).
+		Order("next_attempt asc").
+		Find(&deliveries).Error
+
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		claimed, err := w.claim(ctx, delivery)
+
+		if err != nil {
+			println("claiming webhook delivery threw error", err.Error())
+			continue
+		}
+
+		if !claimed {
+			// delivered by another instance
+			continue
+		}
+
+		err = w.deliver(ctx, delivery)
+
+		if err != nil {
+			println("delivering webhook threw error", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// claim marks a due delivery as delivering, false when another instance claimed it first
+func (w *Webhooks) claim(ctx context.Context, delivery *Delivery) (bool, error) {
+	now := time.Now()
+	timeout := now.Add(claimTimeout)
+	result := w.db.
+		WithContext(ctx).
+		Table(Delivery{}.Name()).
+		Where("id = ? AND status IN ? AND next_attempt <= ?", delivery.ID, []string{StatusPending, StatusDelivering}, now).
+		Updates(map[string]any{
+			"status":       StatusDelivering,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"next_attempt": timeout,
+			"updated_at":   now,
+		})
+
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	delivery.Status = StatusDelivering
+	delivery.Attempts++
+	delivery.NextAttempt = &timeout
+
+	return true, nil
+}
+
+// deliver makes one attempt at delivering a claimed delivery and updates the log with the outcome
+func (w *Webhooks) deliver(ctx context.Context, delivery *Delivery) error {
+	subscription := &Subscription{}
+	err := w.db.
+		WithContext(ctx).
+		Table(Subscription{}.Name()).
+		Where("id = ?", delivery.SubscriptionID).
+		First(subscription).Error
+
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		subscription = nil
+	}
+
+	delivery.ResponseCode = 0
+	delivery.LastError = ""
+
+	if subscription == nil {
+		delivery.LastError = "subscription not found"
+	} else {
+		delivery.ResponseCode, err = w.send(ctx, subscription, delivery)
+
+		if err != nil {
+			delivery.LastError = err.Error()
+		}
+	}
+
+	if delivery.LastError == "" {
+		delivery.Status = StatusDelivered
+		delivery.NextAttempt = nil
+	} else if subscription == nil || delivery.Attempts >= w.maxAttempts {
+		delivery.Status = StatusFailed
+		delivery.NextAttempt = nil
+	} else {
+		next := time.Now().Add(w.retryIn(delivery.Attempts))
+		delivery.Status = StatusPending
+		delivery.NextAttempt = &next
+	}
+
+	return w.db.
+		WithContext(ctx).
+		Table(Delivery{}.Name()).
+		Save(delivery).Error
+}
+
+// retryIn is the exponential backoff after a failed attempt, base * 2^(attempts-1) up to maxBackoff
+func (w *Webhooks) retryIn(attempts int) time.Duration {
+	// the shift is capped, larger shifts overflow
+	backoff := w.backoff << min(max(attempts-1, 0), 32)
+
+	if backoff <= 0 || backoff > maxBackoff {
+		return maxBackoff
+	}
+
+	return backoff
+}
+
+func (w *Webhooks) send(ctx context.Context, subscription *Subscription, delivery *Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, fmt.Sprintf("%s.%s", delivery.Entity, delivery.Operation))
+	req.Header.Set(HeaderDelivery, fmt.Sprintf("%d", delivery.ID))
+
+	if subscription.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(subscription.Secret, body))
+	}
+
+	res, err := w.client.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded with %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,389 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Meduzz/quickapi/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSecretIsWriteOnly(t *testing.T) {
+	subscription := &Subscription{}
+	err := json.Unmarshal([]byte(`{"url":"http://localhost","secret":"s3cret"}`), subscription)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subscription.Secret != "s3cret" {
+		t.Fatalf("secret was not read, got %q", subscription.Secret)
+	}
+
+	for _, it := range []any{subscription, *subscription, []*Subscription{subscription}} {
+		bs, err := json.Marshal(it)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(bs), "s3cret") || strings.Contains(string(bs), `"secret"`) {
+			t.Fatalf("secret was written: %s", bs)
+		}
+	}
+
+	if subscription.Secret != "s3cret" {
+		t.Fatal("marshalling cleared the secret")
+	}
+}
+
+func TestRetryIn(t *testing.T) {
+	w := New(nil, WithRetries(100, time.Second))
+
+	cases := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{13, maxBackoff},
+		{64, maxBackoff},
+		{65, maxBackoff},
+		{1000, maxBackoff},
+	}
+
+	for _, c := range cases {
+		if actual := w.retryIn(c.attempts); actual != c.expected {
+			t.Errorf("attempt %d: expected %s, got %s", c.attempts, c.expected, actual)
+		}
+	}
+}
+
+// database opens an in memory sqlite db with the webhook tables
+func database(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one connection, every connection gets an in memory db of its own
+	pool, err := db.DB()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.SetMaxOpenConns(1)
+
+	err = db.Table(Subscription{}.Name()).AutoMigrate(&Subscription{})
+
+	if err == nil {
+		err = db.Table(Delivery{}.Name()).AutoMigrate(&Delivery{})
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func subscribe(t *testing.T, db *gorm.DB, subscriptions ...*Subscription) {
+	t.Helper()
+
+	for _, it := range subscriptions {
+		err := db.Table(Subscription{}.Name()).Create(it).Error
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func deliveries(t *testing.T, db *gorm.DB) []*Delivery {
+	t.Helper()
+
+	result := make([]*Delivery, 0)
+	err := db.Table(Delivery{}.Name()).Order("id").Find(&result).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestPublishFilters(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+	w := New(db)
+
+	subscribe(t, db,
+		&Subscription{URL: "http://all"},
+		&Subscription{URL: "http://creates", Events: []string{"create"}},
+		&Subscription{URL: "http://pets", Entities: []string{"pets"}},
+		&Subscription{URL: "http://pet-creates", Events: []string{"create"}, Entities: []string{"pets"}},
+		&Subscription{URL: "http://disabled", Disabled: true},
+	)
+
+	events := []*model.Event{
+		model.NewEvent("pets", model.OperationCreate, "1", nil),
+		model.NewEvent("pets", model.OperationUpdate, "1", nil),
+		model.NewEvent("persons", model.OperationCreate, "1", nil),
+		model.NewEvent(Subscription{}.Name(), model.OperationCreate, "1", nil),
+	}
+
+	for _, event := range events {
+		err := w.Publish(ctx, event)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	received := make(map[int64][]string)
+
+	for _, it := range deliveries(t, db) {
+		received[it.SubscriptionID] = append(received[it.SubscriptionID], fmt.Sprintf("%s.%s", it.Entity, it.Operation))
+
+		if it.Status != StatusPending || it.NextAttempt == nil {
+			t.Errorf("expected a pending delivery, got %s", it.Status)
+		}
+	}
+
+	expected := map[int64][]string{
+		1: {"pets.create", "pets.update", "persons.create"},
+		2: {"pets.create", "persons.create"},
+		3: {"pets.create", "pets.update"},
+		4: {"pets.create"},
+	}
+
+	if !reflect.DeepEqual(received, expected) {
+		t.Fatalf("expected %v, got %v", expected, received)
+	}
+}
+
+func TestDeliverySignature(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+	w := New(db)
+
+	var headers http.Header
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		headers = req.Header
+		body, _ = io.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	subscribe(t, db, &Subscription{URL: server.URL, Secret: "s3cret"})
+
+	err := w.Publish(ctx, model.NewEvent("pets", model.OperationCreate, "1", map[string]any{"species": "cat"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.deliverDue(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if headers.Get(HeaderSignature) != Sign("s3cret", body) || !strings.HasPrefix(headers.Get(HeaderSignature), "sha256=") {
+		t.Fatalf("expected the signature of %s, got %s", body, headers.Get(HeaderSignature))
+	}
+
+	if headers.Get(HeaderEvent) != "pets.create" || headers.Get(HeaderDelivery) != "1" {
+		t.Fatalf("expected pets.create delivery 1, got %s %s", headers.Get(HeaderEvent), headers.Get(HeaderDelivery))
+	}
+
+	event := &model.Event{}
+	err = json.Unmarshal(body, event)
+
+	if err != nil || event.Entity != "pets" || event.ID != "1" {
+		t.Fatalf("expected the event as body, got %s", body)
+	}
+
+	if it := deliveries(t, db)[0]; it.Status != StatusDelivered || it.Attempts != 1 || it.ResponseCode != 200 {
+		t.Fatalf("expected a delivery in 1 attempt, got %+v", it)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+	w := New(db, WithRetries(2, time.Millisecond))
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	subscribe(t, db, &Subscription{URL: server.URL})
+
+	err := w.Publish(ctx, model.NewEvent("pets", model.OperationCreate, "1", nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status   string
+		attempts int
+	}{
+		{StatusPending, 1}, // retried
+		{StatusFailed, 2},  // out of attempts
+		{StatusFailed, 2},  // left alone
+	}
+
+	for _, test := range tests {
+		time.Sleep(5 * time.Millisecond)
+		err = w.deliverDue(ctx)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		it := deliveries(t, db)[0]
+
+		if it.Status != test.status || it.Attempts != test.attempts || it.ResponseCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected %s after %d attempts, got %+v", test.status, test.attempts, it)
+		}
+
+		if (it.Status == StatusPending) != (it.NextAttempt != nil) {
+			t.Fatalf("expected a next attempt only when pending, got %v", it.NextAttempt)
+		}
+	}
+}
+
+func TestDeliveryWithoutSubscriptionFails(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+	w := New(db)
+
+	subscribe(t, db, &Subscription{URL: "http://localhost"})
+
+	err := w.Publish(ctx, model.NewEvent("pets", model.OperationCreate, "1", nil))
+
+	if err == nil {
+		err = db.Table(Subscription{}.Name()).Where("id = ?", 1).Delete(&Subscription{}).Error
+	}
+
+	if err == nil {
+		err = w.deliverDue(ctx)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if it := deliveries(t, db)[0]; it.Status != StatusFailed || it.LastError != "subscription not found" {
+		t.Fatalf("expected a failed delivery, got %+v", it)
+	}
+}
+
+func TestClaimOnce(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+	first, second := New(db), New(db)
+
+	subscribe(t, db, &Subscription{URL: "http://localhost"})
+
+	err := first.Publish(ctx, model.NewEvent("pets", model.OperationCreate, "1", nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both instances found the delivery due
+	found := deliveries(t, db)[0]
+	copied := *found
+
+	claimed, err := first.claim(ctx, found)
+
+	if err != nil || !claimed {
+		t.Fatalf("expected the first claim to win, got %v %v", claimed, err)
+	}
+
+	claimed, err = second.claim(ctx, &copied)
+
+	if err != nil || claimed {
+		t.Fatalf("expected the second claim to lose, got %v %v", claimed, err)
+	}
+
+	// a claim that timed out (ie the instance died) is claimed again
+	past := time.Now().Add(-time.Second)
+	err = db.Table(Delivery{}.Name()).Where("id = ?", found.ID).Update("next_attempt", past).Error
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err = second.claim(ctx, &copied)
+
+	if err != nil || !claimed {
+		t.Fatalf("expected a timed out claim to be claimed again, got %v %v", claimed, err)
+	}
+
+	if it := deliveries(t, db)[0]; it.Status != StatusDelivering || it.Attempts != 2 {
+		t.Fatalf("expected a second attempt in progress, got %+v", it)
+	}
+}
+
+func TestDeliverDueContinuesAfterErrors(t *testing.T) {
+	ctx := context.Background()
+	db := database(t)
+	w := New(db)
+
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	subscribe(t, db, &Subscription{URL: server.URL})
+
+	for range 2 {
+		err := w.Publish(ctx, model.NewEvent("pets", model.OperationCreate, "1", nil))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// saving the outcome of the first delivery fails
+	err := db.Callback().Update().Before("gorm:update").Register("fail_first", func(tx *gorm.DB) {
+		if it, ok := tx.Statement.Dest.(*Delivery); ok && it.ID == 1 {
+			tx.AddError(errors.New("disk full"))
+		}
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.deliverDue(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := deliveries(t, db)
+
+	if found[0].Status != StatusDelivering || found[1].Status != StatusDelivered {
+		t.Fatalf("expected the second delivery to be delivered, got %s and %s", found[0].Status, found[1].Status)
+	}
+}